@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	_ "time/tzdata"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/deadletter"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/planner"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+// result is what the planning run returns to the caller.
+type result struct {
+	Created   int `json:"created"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Booked    int `json:"booked"`
+	Skipped   int `json:"skipped"`
+	Deleted   int `json:"deleted"`
+
+	// Digest is set for digest runs instead of the counts.
+	Digest *planner.Digest `json:"digest,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// event picks what the invocation runs. An empty event plans.
+type event struct {
+	// Job is plan or digest.
+	Job string `json:"job"`
+}
+
+type handler struct {
+	job *planner.Job
+}
+
+// HandleLambdaEvent runs one planning pass, or sends the booking digest for
+// {"job": "digest"}. It's invoked by recurring EventBridge schedules.
+func (h *handler) HandleLambdaEvent(ctx context.Context, ev event) (result, error) {
+	switch ev.Job {
+	case "", "plan":
+	case "digest":
+		d, err := h.job.SendDigest(ctx)
+		res := result{Digest: d}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		return res, err
+	default:
+		return result{}, fmt.Errorf("unknown job %q, expected plan or digest", ev.Job)
+	}
+
+	plan, err := h.job.Run(ctx)
+	if plan == nil {
+		return result{}, err
+	}
+	res := result{
+		Created:   plan.Count(scheduler.ActionCreate),
+		Updated:   plan.Count(scheduler.ActionUpdate),
+		Unchanged: plan.Count(scheduler.ActionUnchanged),
+		Booked:    plan.Count(scheduler.ActionBook),
+		Skipped:   plan.Count(scheduler.ActionSkip),
+		Deleted:   plan.Count(scheduler.ActionDelete),
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res, err
+}
+
+func main() {
+	// the lambda is configured through RSVPER_* environment variables
+	cfg, err := config.Load("")
+	if err != nil {
+		fmt.Printf("unable to load config: %s\n", err)
+		os.Exit(1)
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWSRegion),
+	})
+	if err != nil {
+		fmt.Printf("unable to create new session: %s\n", err)
+		os.Exit(1)
+	}
+	backend, err := planner.NewEventBridgeBackend(sess, cfg)
+	if err != nil {
+		fmt.Printf("unable to create trigger backend: %s\n", err)
+		os.Exit(1)
+	}
+	store, err := secrets.Open(cfg)
+	if err != nil {
+		fmt.Printf("unable to open secret store: %s\n", err)
+		os.Exit(1)
+	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		fmt.Printf("unable to create notifiers: %s\n", err)
+		os.Exit(1)
+	}
+	job, err := planner.NewJob(cfg, backend, store, notifier)
+	if err != nil {
+		fmt.Printf("unable to create planning job: %s\n", err)
+		os.Exit(1)
+	}
+	ledger, err := booking.OpenLedger(cfg)
+	if err != nil {
+		fmt.Printf("unable to open idempotency ledger: %s\n", err)
+		os.Exit(1)
+	}
+	job.SetLedger(ledger)
+	if cfg.DeadLetterARN != "" {
+		queue, err := deadletter.NewQueue(sess, cfg.DeadLetterARN)
+		if err != nil {
+			fmt.Printf("unable to open dead letter queue: %s\n", err)
+			os.Exit(1)
+		}
+		job.SetFailures(planner.DeadLetterFailures{Queue: queue})
+	}
+
+	h := handler{job: job}
+	lambda.Start(h.HandleLambdaEvent)
+}
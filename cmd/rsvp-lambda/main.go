@@ -3,56 +3,68 @@ package main
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"strings"
-	"time"
+	"os"
+	_ "time/tzdata"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/twilio/twilio-go"
-	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 
-	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/notify"
 	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
 )
 
-func HandleLambdaEvent(ctx context.Context, event scheduler.TaskRequest) (string, error) {
-	fmt.Printf("received event: %+v\n", event)
-	c := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
-	}
-	s, err := cfa.NewService(c)
+type handler struct {
+	booker *booking.Booker
+}
+
+// HandleLambdaEvent books the task's class and returns how it went. The
+// result is also logged as a json line, since lambda drops it when the
+// invocation fails.
+func (h *handler) HandleLambdaEvent(ctx context.Context, event scheduler.TaskRequest) (*booking.Result, error) {
+	// the event only references credentials, but keep logs to what's needed
+	fmt.Printf("received task for class %d at %s, credentials %s\n", event.Schedule.ID, event.Schedule.Start, event.Credentials)
+	res, err := h.booker.Run(ctx, event)
+	fmt.Printf("result: %s\n", res.JSON())
 	if err != nil {
-		return "", fmt.Errorf("unable to create cfa service: %w", err)
+		return nil, fmt.Errorf("%s: %w", res.ErrorCategory, err)
 	}
-	s.SetCookie(event.CFACookie)
 
-	twilioClient := twilio.NewRestClient()
-	params := &twilioApi.CreateMessageParams{}
-	params.SetTo("+18186247532")
-	params.SetFrom("+15075017519")
+	return res, nil
+}
 
-	status, err := s.PollRSVP(ctx, event.Schedule)
+func main() {
+	// the lambda is configured through RSVPER_* environment variables
+	cfg, err := config.Load("")
 	if err != nil {
-		params.SetBody(fmt.Sprintf("unable to poll rsvp: %v", err))
-		if _, smsErr := twilioClient.Api.CreateMessage(params); smsErr != nil {
-			fmt.Printf("unable to send sms: %s", err)
-		}
-		return "", fmt.Errorf("unable to poll rsvp: %w", err)
+		fmt.Printf("unable to load config: %s\n", err)
+		os.Exit(1)
 	}
+	fmt.Printf("running in %s, gym timezone %s\n", cfg.AWSRegion, cfg.Timezone)
 
-	fmt.Printf("rsvp status: %s\n", status.String())
-	text := fmt.Sprintf("successfully submitted rsvp request for class: %s, with rsvp status: %s", strings.Replace(event.Schedule.Title, "\n", " ", 1), status.String())
-	params.SetBody(text)
-	if _, smsErr := twilioClient.Api.CreateMessage(params); smsErr != nil {
-		fmt.Printf("unable to send sms: %s", err)
+	store, err := secrets.Open(cfg)
+	if err != nil {
+		fmt.Printf("unable to open secret store: %s\n", err)
+		os.Exit(1)
 	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		fmt.Printf("unable to create notifiers: %s\n", err)
+		os.Exit(1)
+	}
+	booker, err := booking.NewBooker(store, notifier)
+	if err != nil {
+		fmt.Printf("unable to create booker: %s\n", err)
+		os.Exit(1)
+	}
+	ledger, err := booking.OpenLedger(cfg)
+	if err != nil {
+		fmt.Printf("unable to open idempotency ledger: %s\n", err)
+		os.Exit(1)
+	}
+	booker.SetLedger(ledger)
 
-	return status.String(), nil
-}
-
-func main() {
-	lambda.Start(HandleLambdaEvent)
+	h := handler{booker: booker}
+	lambda.Start(h.HandleLambdaEvent)
 }
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/jobstore"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/planner"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+const (
+	storeFilePath = "rsvperd.json"
+	// maxSleep bounds how long the daemon sleeps between checks so jobs
+	// added while it's sleeping are picked up.
+	maxSleep = time.Minute
+	// jobTimeout caps a single booking, the same as the lambda timeout.
+	jobTimeout = 15 * time.Minute
+)
+
+type daemon struct {
+	store  *jobstore.Store
+	booker *booking.Booker
+	sem    chan struct{}
+	wg     sync.WaitGroup
+}
+
+func main() {
+	storePath := flag.String("store", storeFilePath, "path to the job store")
+	configPath := flag.String("config", "", "path to the config file, defaults to $RSVPER_CONFIG or "+config.DefaultPath)
+	concurrency := flag.Int("concurrency", 8, "maximum number of jobs to run at once")
+	planEvery := flag.Duration("plan-every", 0, "also run the planning job at start and then this often, e.g. 24h")
+	digest := flag.Bool("digest", false, "also send the booking digest every day or week, per the config")
+	flag.Parse()
+
+	if *concurrency < 1 {
+		log.Fatalf("concurrency must be at least 1")
+	}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("unable to load config: %v", err)
+	}
+	secretStore, err := secrets.Open(cfg)
+	if err != nil {
+		log.Fatalf("unable to open secret store: %v", err)
+	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("unable to create notifiers: %v", err)
+	}
+	booker, err := booking.NewBooker(secretStore, notifier)
+	if err != nil {
+		log.Fatalf("unable to create booker: %v", err)
+	}
+	ledger, err := booking.OpenLedger(cfg)
+	if err != nil {
+		log.Fatalf("unable to open idempotency ledger: %v", err)
+	}
+	booker.SetLedger(ledger)
+	store, err := jobstore.Open(*storePath)
+	if err != nil {
+		log.Fatalf("unable to open job store: %v", err)
+	}
+	release, err := store.Acquire()
+	if err != nil {
+		log.Fatalf("unable to start: %v", err)
+	}
+	defer release()
+
+	// jobs left running by a previous daemon never finished, run them again
+	requeued, err := store.Requeue()
+	if err != nil {
+		log.Fatalf("unable to requeue jobs: %v", err)
+	}
+	if requeued > 0 {
+		fmt.Printf("requeued %d unfinished job(s)\n", requeued)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	d := daemon{
+		store:  store,
+		booker: booker,
+		sem:    make(chan struct{}, *concurrency),
+	}
+	var job *planner.Job
+	if *planEvery > 0 || *digest {
+		if job, err = planner.NewJob(cfg, store, secretStore, notifier); err != nil {
+			log.Fatalf("unable to create planning job: %v", err)
+		}
+		job.SetFailures(planner.JobStoreFailures{Store: store})
+		job.SetLedger(ledger)
+	}
+	bgCtx, stopBackground := context.WithCancel(ctx)
+	var background sync.WaitGroup
+	if *planEvery > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			d.plan(bgCtx, job, *planEvery)
+		}()
+	}
+	if *digest {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			d.digest(bgCtx, job, cfg.DigestPeriod())
+		}()
+	}
+	fmt.Printf("rsvperd started, store: %s\n", *storePath)
+	err = d.run(ctx)
+	stopBackground()
+	background.Wait()
+	if err != nil {
+		log.Fatalf("rsvperd stopped: %v", err)
+	}
+	fmt.Println("rsvperd stopped")
+}
+
+func (d *daemon) run(ctx context.Context) error {
+	defer d.wg.Wait()
+
+	for {
+		jobs, err := d.store.Claim(time.Now())
+		if err != nil {
+			return fmt.Errorf("unable to claim jobs: %w", err)
+		}
+		for i := range jobs {
+			d.wg.Add(1)
+			go d.runJob(ctx, jobs[i])
+		}
+
+		sleep := maxSleep
+		next, ok, err := d.store.Next()
+		if err != nil {
+			return fmt.Errorf("unable to get next job: %w", err)
+		}
+		if ok && time.Until(next) < sleep {
+			sleep = time.Until(next)
+		}
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Println("shutting down, waiting for running jobs")
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// plan runs the planning job now and then every interval until ctx is done.
+// Triggers it plans land in the daemon's store and are picked up by run.
+func (d *daemon) plan(ctx context.Context, job *planner.Job, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		fmt.Println("running planning job")
+		if plan, err := job.Run(ctx); err != nil {
+			fmt.Printf("planning job failed: %v\n", err)
+		} else {
+			plan.WriteSummary(os.Stdout)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// digest sends the booking digest every period until ctx is done. The first
+// one goes out a period after start so restarts don't resend it.
+func (d *daemon) digest(ctx context.Context, job *planner.Job, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fmt.Println("sending digest")
+		if _, err := job.SendDigest(ctx); err != nil {
+			fmt.Printf("digest failed: %v\n", err)
+		}
+	}
+}
+
+func (d *daemon) runJob(ctx context.Context, job jobstore.Job) {
+	defer d.wg.Done()
+
+	name := job.Trigger.Name
+	class := job.Trigger.Task.Schedule
+	// jobs that were due while the daemon was down are only worth running
+	// while the class can still be booked
+	if class.Start == nil || !time.Now().Before(*class.Start) {
+		fmt.Printf("job %s missed, class already started\n", name)
+		d.finish(name, jobstore.StatusMissed, "", fmt.Errorf("class started before the job could run"))
+		return
+	}
+	if late := time.Since(job.Trigger.Time); late > maxSleep {
+		fmt.Printf("catching up on job %s, %s late\n", name, late.Round(time.Second))
+	}
+
+	select {
+	case d.sem <- struct{}{}:
+	case <-ctx.Done():
+		d.finish(name, jobstore.StatusPending, "", nil)
+		return
+	}
+	defer func() { <-d.sem }()
+
+	fmt.Printf("running job %s\n", name)
+	jobCtx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	res, err := d.booker.Run(jobCtx, job.Trigger.Task)
+	fmt.Printf("job %s result: %s\n", name, res.JSON())
+	switch {
+	case ctx.Err() != nil:
+		// interrupted by shutdown, run it again on the next start
+		fmt.Printf("job %s interrupted, requeueing\n", name)
+		d.finish(name, jobstore.StatusPending, "", nil)
+	case err != nil:
+		fmt.Printf("job %s failed: %v\n", name, err)
+		d.finish(name, jobstore.StatusFailed, "", err)
+	default:
+		fmt.Printf("job %s done: %s\n", name, res.Status)
+		d.finish(name, jobstore.StatusDone, res.Status, nil)
+	}
+}
+
+func (d *daemon) finish(name string, status jobstore.Status, result string, jobErr error) {
+	if err := d.store.Finish(name, status, result, jobErr); err != nil {
+		fmt.Printf("unable to record job %s as %s: %v\n", name, status, err)
+	}
+}
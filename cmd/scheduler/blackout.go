@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/blackout"
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+const blackoutUsage = `usage: scheduler blackout <add|list|remove> [flags]
+
+  add -from <date> -to <date> [-reason text] [-unregister]
+        add a blackout period, cancelling triggers for classes inside it.
+        dates are yyyy-mm-dd (the end date is inclusive) or RFC3339 times
+  list  list blackout periods
+  remove <number>
+        remove the blackout period with the number shown by list
+`
+
+func blackoutCmd(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, blackoutUsage)
+		return fmt.Errorf("missing blackout command")
+	}
+
+	switch args[0] {
+	case "add":
+		return blackoutAdd(args[1:])
+	case "list":
+		return blackoutList(args[1:])
+	case "remove":
+		return blackoutRemove(args[1:])
+	default:
+		fmt.Fprint(os.Stderr, blackoutUsage)
+		return fmt.Errorf("unknown blackout command: %s", args[0])
+	}
+}
+
+func blackoutAdd(args []string) error {
+	fs := flag.NewFlagSet("blackout add", flag.ExitOnError)
+	path := fs.String("blackouts", blackoutsPath, "path to the blackouts file")
+	common := addCommonFlags(fs)
+	from := fs.String("from", "", "first day of the blackout, yyyy-mm-dd or RFC3339")
+	to := fs.String("to", "", "last day of the blackout, yyyy-mm-dd or RFC3339")
+	reason := fs.String("reason", "", "why the blackout exists, e.g. vacation")
+	unregister := fs.Bool("unregister", false, "also unregister from classes already booked inside the blackout")
+	fs.Parse(args)
+
+	if *from == "" || *to == "" {
+		return fmt.Errorf("-from and -to are required")
+	}
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	loc := cfg.Location()
+	period, err := blackout.NewPeriod(*from, *to, *reason, loc)
+	if err != nil {
+		return err
+	}
+
+	periods, err := blackout.Load(*path)
+	if err != nil {
+		return err
+	}
+	periods = append(periods, period)
+	if err := blackout.Save(*path, periods); err != nil {
+		return err
+	}
+	fmt.Printf("added blackout %s\n", period)
+
+	cfaService, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	secretStore, err := setAccount(cfg, schedulerService)
+	if err != nil {
+		return err
+	}
+
+	// cancel the account's pending triggers for classes inside the blackout
+	cancelled, err := schedulerService.CancelTriggers(func(t scheduler.Trigger) bool {
+		return t.Task.Schedule.Start != nil && period.Contains(*t.Task.Schedule.Start)
+	})
+	// report whatever was cancelled before a possible failure
+	printTriggers("cancelled", cancelled)
+	if err != nil {
+		return fmt.Errorf("unable to cancel triggers: %w", err)
+	}
+
+	if !*unregister {
+		return nil
+	}
+
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to create notifiers: %w", err)
+	}
+	creds, err := login(cfaService, secretStore, cfg.Credentials)
+	if err != nil {
+		return err
+	}
+	fmt.Println("successfully logged in")
+	schedule, err := cfaService.GetSchedule(cfa.ScheduleParams{
+		Name:      cfa.InHouseSessions,
+		StartDate: period.Start.In(loc).Format("2006-01-02"),
+		EndDate:   period.End.In(loc).Format("2006-01-02"),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to get schedule: %w", err)
+	}
+
+	var unregistered []cfa.Schedule
+	for i := range schedule {
+		if schedule[i].Start == nil || !period.Contains(*schedule[i].Start) {
+			continue
+		}
+		status, err := cfaService.CheckRSVP(schedule[i])
+		if err != nil {
+			return fmt.Errorf("unable to check rsvp for class %d: %w", schedule[i].ID, err)
+		}
+		if status != cfa.RSVPED && status != cfa.WAITLISTED {
+			continue
+		}
+		if _, err := cfaService.Unregister(schedule[i]); err != nil {
+			return fmt.Errorf("unable to unregister from class %d: %w", schedule[i].ID, err)
+		}
+		unregistered = append(unregistered, schedule[i])
+		fmt.Printf("unregistered from class %s at %s, was %s\n", classTitle(schedule[i]), schedule[i].Start.Format(time.RFC3339), status)
+		event := notify.Event{
+			Kind:    notify.KindCancelled,
+			Class:   schedule[i],
+			Account: creds.Username,
+			Message: "inside blackout " + period.String(),
+		}
+		if err := notifier.Notify(context.Background(), event); err != nil {
+			fmt.Printf("unable to send notification: %v\n", err)
+		}
+	}
+	fmt.Printf("unregistered from %d class(es)\n", len(unregistered))
+
+	return nil
+}
+
+func blackoutList(args []string) error {
+	fs := flag.NewFlagSet("blackout list", flag.ExitOnError)
+	path := fs.String("blackouts", blackoutsPath, "path to the blackouts file")
+	fs.Parse(args)
+
+	periods, err := blackout.Load(*path)
+	if err != nil {
+		return err
+	}
+	if len(periods) == 0 {
+		fmt.Println("no blackouts")
+		return nil
+	}
+	for i := range periods {
+		fmt.Printf("%d. %s\n", i+1, periods[i])
+	}
+
+	return nil
+}
+
+func blackoutRemove(args []string) error {
+	fs := flag.NewFlagSet("blackout remove", flag.ExitOnError)
+	path := fs.String("blackouts", blackoutsPath, "path to the blackouts file")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the number of the blackout to remove")
+	}
+	n, err := strconv.Atoi(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("invalid blackout number %q", fs.Arg(0))
+	}
+	periods, err := blackout.Load(*path)
+	if err != nil {
+		return err
+	}
+	if n < 1 || n > len(periods) {
+		return fmt.Errorf("no blackout number %d, there are %d", n, len(periods))
+	}
+	removed := periods[n-1]
+	periods = append(periods[:n-1], periods[n:]...)
+	if err := blackout.Save(*path, periods); err != nil {
+		return err
+	}
+	fmt.Printf("removed blackout %s\n", removed)
+
+	return nil
+}
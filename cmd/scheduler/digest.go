@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/deadletter"
+	"github.com/itsHabib/rsvper/internal/jobstore"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/planner"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+// digestCmd previews the booking digest, or sends it with -send.
+func digestCmd(args []string) error {
+	fs := flag.NewFlagSet("digest", flag.ExitOnError)
+	period := fs.String("period", "", "daily or weekly, defaults to the configured digest")
+	send := fs.Bool("send", false, "send the digest to the configured notifiers instead of only printing it")
+	asJSON := fs.Bool("json", false, "print the digest as json")
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	switch *period {
+	case "":
+	case config.DigestDaily, config.DigestWeekly:
+		cfg.Digest = *period
+	default:
+		return fmt.Errorf("invalid period %q, expected %s or %s", *period, config.DigestDaily, config.DigestWeekly)
+	}
+
+	out := os.Stdout
+	// keep progress logs out of the digest
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	job, err := newDigestJob(cfg, common)
+	if err != nil {
+		return err
+	}
+	var d *planner.Digest
+	if *send {
+		d, err = job.SendDigest(context.Background())
+	} else {
+		d, err = job.Digest(context.Background())
+	}
+	if d == nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if encErr := enc.Encode(d); encErr != nil {
+			return encErr
+		}
+	} else {
+		d.WriteText(out, cfg.Location())
+	}
+
+	return err
+}
+
+// newDigestJob returns a planning job that reads failures from the rsvperd
+// job store with -backend local, or from the dead letter queue when one is
+// configured.
+func newDigestJob(cfg *config.Config, common commonFlags) (*planner.Job, error) {
+	backend, err := common.triggerBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	secretStore, err := secrets.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open secret store: %w", err)
+	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create notifiers: %w", err)
+	}
+	job, err := planner.NewJob(cfg, backend, secretStore, notifier)
+	if err != nil {
+		return nil, err
+	}
+	// the lambda's ledger tells which booked classes came off the wait list
+	ledger, err := booking.OpenLedger(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open idempotency ledger: %w", err)
+	}
+	job.SetLedger(ledger)
+
+	switch store := backend.(type) {
+	case *jobstore.Store:
+		job.SetFailures(planner.JobStoreFailures{Store: store})
+	default:
+		if cfg.DeadLetterARN == "" {
+			break
+		}
+		sess, err := getAWSSession(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get aws session: %w", err)
+		}
+		queue, err := deadletter.NewQueue(sess, cfg.DeadLetterARN)
+		if err != nil {
+			return nil, err
+		}
+		job.SetFailures(planner.DeadLetterFailures{Queue: queue})
+	}
+
+	return job, nil
+}
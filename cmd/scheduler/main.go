@@ -1,140 +1,479 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
-	"sort"
+	"strings"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 
+	"github.com/itsHabib/rsvper/internal/blackout"
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/calendar"
 	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/jobstore"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/planner"
+	"github.com/itsHabib/rsvper/internal/requestfile"
 	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+	"github.com/itsHabib/rsvper/internal/shorthand"
 )
 
 const (
-	awsRegion       = "us-east-2"
 	requestFilePath = "cmd/scheduler/requests.json"
-	credsFilePath   = "cmd/scheduler/creds.txt"
+	blackoutsPath   = "cmd/scheduler/blackouts.json"
+	jobStorePath    = "rsvperd.json"
 )
 
-var (
-	username string
-	password string
-)
+const usage = `usage: scheduler [command] [flags]
+
+commands:
+  run       schedule rsvp triggers for the requests file (default)
+  plan      show what run would do without changing anything
+  sync      make the triggers match the requests file, deleting the rest
+  replay    re-run failed task requests from the dead letter queue, a file or rsvperd
+  digest    preview or send the daily or weekly booking digest
+  validate  check a requests file without logging in
+  blackout  add, list or remove blackout periods
+  list      list pending triggers
+  show      show a trigger's details
+  cancel    cancel pending triggers by name, class or date
+  purge     delete triggers that already fired
+  gc        delete triggers for classes that already started
+`
 
 func main() {
-	c := &http.Client{
-		Timeout: 10 * time.Second,
+	cmd, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
-	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
-		return http.ErrUseLastResponse
+
+	var err error
+	switch cmd {
+	case "run":
+		err = run(args)
+	case "plan":
+		err = planCmd(args)
+	case "sync":
+		err = syncCmd(args)
+	case "replay":
+		err = replayCmd(args)
+	case "digest":
+		err = digestCmd(args)
+	case "validate":
+		err = validate(args)
+	case "blackout":
+		err = blackoutCmd(args)
+	case "list":
+		err = listCmd(args)
+	case "show":
+		err = showCmd(args)
+	case "cancel":
+		err = cancelCmd(args)
+	case "purge":
+		err = purgeCmd(args)
+	case "gc":
+		err = gcCmd(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		err = fmt.Errorf("unknown command: %s", cmd)
 	}
-	cfaService, err := cfa.NewService(c)
 	if err != nil {
-		log.Fatalf("unable to create cfa service: %v", err)
+		log.Fatalf("unable to %s: %v", cmd, err)
 	}
-	sess, err := getAWSSession()
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	pf := addPlanFlags(fs)
+	fs.Parse(args)
+
+	schedulerService, plan, err := pf.plan()
 	if err != nil {
-		log.Fatalf("unable to get aws session: %v", err)
+		return err
 	}
-	schedulerService, err := scheduler.NewService(sess)
-	if err != nil {
-		log.Fatalf("unable to create scheduler service: %v", err)
+	applyErr := schedulerService.Apply(context.Background(), plan)
+	fmt.Println("plan:")
+	plan.WriteSummary(os.Stdout)
+	if applyErr != nil {
+		return fmt.Errorf("unable to apply plan: %w", applyErr)
 	}
 
-	// get requests file
-	requestsFile, err := os.Open(requestFilePath)
+	return nil
+}
+
+// planCmd shows what run would do without changing any triggers.
+func planCmd(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ExitOnError)
+	pf := addPlanFlags(fs)
+	asJSON := fs.Bool("json", false, "print the plan as json")
+	fs.BoolVar(&pf.prune, "sync", false, "plan like sync, deleting triggers that are no longer requested")
+	fs.Parse(args)
+
+	if *asJSON {
+		// keep progress logs out of the json
+		pf.log = os.Stderr
+	}
+	_, plan, err := pf.plan()
 	if err != nil {
-		log.Fatalf("unable to open requests file: %v", err)
+		return err
 	}
-	var requests []cfa.ScheduleRequest
-	if err := json.NewDecoder(requestsFile).Decode(&requests); err != nil {
-		log.Fatalf("unable to decode requests file: %v", err)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
 	}
-	if len(requests) == 0 {
-		fmt.Printf("no requests to process\n")
-		return
+	fmt.Println("plan, nothing was changed:")
+	plan.WriteSummary(os.Stdout)
+
+	return nil
+}
+
+// planFlags are the flags shared by the commands that plan triggers.
+type planFlags struct {
+	fs *flag.FlagSet
+	// prune plans the deletion of pending triggers that aren't requested
+	// anymore, treating the requests as the desired state.
+	prune     bool
+	common    commonFlags
+	requests  *string
+	blackouts *string
+	icsBuffer *time.Duration
+	icsMode   *string
+	icsPaths  stringsFlag
+	classes   stringsFlag
+	// log is where progress is logged while planning.
+	log io.Writer
+}
+
+func addPlanFlags(fs *flag.FlagSet) *planFlags {
+	pf := planFlags{
+		fs:        fs,
+		requests:  fs.String("requests", requestFilePath, "path to the requests file (json, yaml or toml)"),
+		blackouts: fs.String("blackouts", blackoutsPath, "path to the blackouts file"),
+		common:    addCommonFlags(fs),
+		icsBuffer: fs.Duration("ics-buffer", 0, "time to keep free before and after each class when checking calendars, e.g. 30m"),
+		icsMode:   fs.String("ics-mode", string(calendar.ModeSkip), "what to do with classes that conflict with a calendar event: skip or flag"),
+		log:       os.Stdout,
 	}
-	fmt.Printf("loaded %d requests\n", len(requests))
-	sort.Slice(requests, func(i, j int) bool {
-		return requests[i].StartTime.Before(*requests[j].StartTime)
-	})
+	fs.Var(&pf.icsPaths, "ics", "ics file or directory of ics files with busy events, can be repeated")
+	fs.Var(&pf.classes, "class", `shorthand class request, e.g. "tue 6:30am small group", can be repeated. the requests file is only read when -requests is also set`)
 
-	// login to set cookie
-	if err := readCreds(); err != nil {
-		log.Fatalf("unable to read creds: %v", err)
+	return &pf
+}
+
+// plan loads the requests, logs in, fetches the schedule and plans triggers
+// for the requests. Nothing is changed until the plan is applied.
+func (pf *planFlags) plan() (*scheduler.Service, *scheduler.Plan, error) {
+	cfg, err := pf.common.loadConfig()
+	if err != nil {
+		return nil, nil, err
 	}
-	cookie, err := cfaService.Login(username, password)
+	cfaService, schedulerService, err := pf.common.newServices(cfg)
 	if err != nil {
-		log.Fatalf("unable to login: %v", err)
+		return nil, nil, err
+	}
+	loc := cfg.Location()
+	cfaService.SetLog(pf.log)
+	schedulerService.SetLog(pf.log)
+	schedulerService.SetLeadTime(cfg.LeadTime)
+	for class, d := range cfg.ClassLeadTimes {
+		schedulerService.SetClassLeadTime(class, d)
+	}
+	// classes whose rsvp window is already open are booked on the spot
+	secretStore, err := secrets.Open(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open secret store: %w", err)
+	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create notifiers: %w", err)
+	}
+	booker, err := booking.NewBooker(secretStore, notifier)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create booker: %w", err)
+	}
+	schedulerService.SetBooker(booker)
+	periods, err := blackout.Load(*pf.blackouts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load blackouts: %w", err)
+	}
+	schedulerService.AddFilter(periods)
+	if len(pf.icsPaths) > 0 {
+		conflicts, err := loadCalendars(pf.log, pf.icsPaths, *pf.icsBuffer, calendar.Mode(*pf.icsMode), loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		schedulerService.AddFilter(conflicts)
 	}
-	fmt.Println("successfully logged in")
 
-	// form get schedule params
-	start := fmt.Sprintf(
-		"%d-%02d-%02d",
-		requests[0].StartTime.Year(),
-		requests[0].StartTime.Month(),
-		requests[0].StartTime.Day(),
+	// get requests from the requests file and the command line
+	var (
+		requests []cfa.ScheduleRequest
+		queries  []shorthand.Query
 	)
-	end := fmt.Sprintf(
-		"%d-%02d-%02d",
-		requests[len(requests)-1].StartTime.Year(),
-		requests[len(requests)-1].StartTime.Month(),
-		requests[len(requests)-1].StartTime.Day(),
-	)
-	params := cfa.ScheduleParams{
-		Name:      cfa.InHouseSessions,
-		StartDate: start,
-		EndDate:   end,
+	if len(pf.classes) == 0 || flagSet(pf.fs, "requests") {
+		file, err := requestfile.Load(*pf.requests, loc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to load requests file: %w", err)
+		}
+		requests, queries = file.Requests, file.Queries
+	}
+	if len(pf.classes) > 0 {
+		for _, class := range pf.classes {
+			q, err := shorthand.Parse(class, time.Now(), loc)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to parse class %q: %w", class, err)
+			}
+			queries = append(queries, q)
+		}
 	}
-	// get schedule
-	schedule, err := cfaService.GetSchedule(params)
+	if len(requests)+len(queries) == 0 && !pf.prune {
+		fmt.Fprintf(pf.log, "no requests to process\n")
+		return schedulerService, &scheduler.Plan{}, nil
+	}
+	fmt.Fprintf(pf.log, "loaded %d requests\n", len(requests)+len(queries))
+
+	// triggers log in with these when they run, so plan with the same
+	// account
+	if err := cfg.RequireCredentials(); err != nil {
+		return nil, nil, err
+	}
+	schedulerService.SetCredentials(cfg.Credentials)
+	creds, err := login(cfaService, secretStore, cfg.Credentials)
 	if err != nil {
-		log.Fatalf("unable to get schedule: %v", err)
+		return nil, nil, err
 	}
+	fmt.Fprintln(pf.log, "successfully logged in")
+	schedulerService.SetAccount(creds.Username)
 
-	// process requests and schedules to create scheduled events
-	if err := schedulerService.ProcessRequests(*cookie, schedule, requests); err != nil {
-		log.Fatalf("unable to process requests: %v", err)
+	p, err := planner.New(cfaService, schedulerService)
+	if err != nil {
+		return nil, nil, err
 	}
+	p.SetLog(pf.log)
+	plan, err := p.Plan(requests, queries, pf.prune)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return schedulerService, plan, nil
 }
 
-func getAWSSession() (*session.Session, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(awsRegion),
+func validate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	printSchema := fs.Bool("schema", false, "print the requests file json schema and exit")
+	configPath := fs.String("config", "", "path to the config file, defaults to $RSVPER_CONFIG or "+config.DefaultPath)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: scheduler validate [-schema] [requests file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *printSchema {
+		_, err := os.Stdout.Write(requestfile.Schema)
+		return err
+	}
+
+	path := requestFilePath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	file, err := requestfile.Load(path, cfg.Location())
+	var errs requestfile.Errors
+	if errors.As(err, &errs) {
+		// print errors as file:line: message so editors can jump to them
+		for _, e := range errs {
+			if e.Line > 0 {
+				fmt.Fprintf(os.Stderr, "%s:%d: ", path, e.Line)
+				e.Line = 0
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: ", path)
+			}
+			fmt.Fprintln(os.Stderr, e.Error())
+		}
+		return fmt.Errorf("%s has %d problem(s)", path, len(errs))
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s is valid, %d request(s)\n", path, len(file.Requests)+len(file.Queries))
+	for i := range file.Queries {
+		fmt.Printf("  %q -> %s at %s\n", file.Queries[i].Text, file.Queries[i].Class, file.Queries[i].Start.Format("Mon Jan 2 15:04 MST"))
+	}
+
+	return nil
+}
+
+func classTitle(sched cfa.Schedule) string {
+	return strings.Replace(sched.Title, "\n", " ", 1)
+}
+
+func flagSet(fs *flag.FlagSet, name string) bool {
+	var set bool
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
 	})
+
+	return set
+}
+
+// stringsFlag is a flag that can be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func loadCalendars(out io.Writer, paths []string, buffer time.Duration, mode calendar.Mode, loc *time.Location) (*calendar.Conflicts, error) {
+	events, warnings, err := calendar.Load(paths, loc)
 	if err != nil {
-		return nil, fmt.Errorf("unable to create new session: %w", err)
+		return nil, fmt.Errorf("unable to load calendars: %w", err)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(out, "warning: %v\n", w)
+	}
+	fmt.Fprintf(out, "loaded %d calendar event(s)\n", len(events))
+	conflicts, err := calendar.NewConflicts(events, buffer, mode)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check calendar conflicts: %w", err)
 	}
 
-	return sess, nil
+	return conflicts, nil
+}
+
+type commonFlags struct {
+	config  *string
+	backend *string
+	store   *string
+}
+
+func addCommonFlags(fs *flag.FlagSet) commonFlags {
+	return commonFlags{
+		config:  fs.String("config", "", "path to the config file, defaults to $RSVPER_CONFIG or "+config.DefaultPath),
+		backend: fs.String("backend", "eventbridge", "where triggers live: eventbridge, or local for the rsvperd job store"),
+		store:   fs.String("store", jobStorePath, "path to the rsvperd job store, used with -backend local"),
+	}
+}
+
+func (f commonFlags) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(*f.config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func (f commonFlags) newServices(cfg *config.Config) (*cfa.Service, *scheduler.Service, error) {
+	c := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cfaService, err := cfa.NewService(c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create cfa service: %w", err)
+	}
+	cfaService.SetLocation(cfg.Location())
+	backend, err := f.triggerBackend(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	schedulerService, err := scheduler.NewService(backend, cfaService)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create scheduler service: %w", err)
+	}
+
+	return cfaService, schedulerService, nil
+}
+
+func (f commonFlags) triggerBackend(cfg *config.Config) (scheduler.TriggerBackend, error) {
+	switch *f.backend {
+	case "eventbridge":
+		sess, err := getAWSSession(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get aws session: %w", err)
+		}
+		return planner.NewEventBridgeBackend(sess, cfg)
+	case "local":
+		store, err := jobstore.Open(*f.store)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open job store: %w", err)
+		}
+		return store, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q, expected eventbridge or local", *f.backend)
+	}
 }
 
-func readCreds() error {
-	f, err := os.Open(credsFilePath)
+// setAccount scopes the scheduler service to the account of the configured
+// credentials, for commands that delete triggers, and returns the secret
+// store it was resolved through.
+func setAccount(cfg *config.Config, schedulerService *scheduler.Service) (secrets.Store, error) {
+	if err := cfg.RequireCredentials(); err != nil {
+		return nil, err
+	}
+	store, err := secrets.Open(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open secret store: %w", err)
+	}
+	creds, err := store.Credentials(cfg.Credentials)
 	if err != nil {
-		return fmt.Errorf("unable to open creds file: %w", err)
+		return nil, fmt.Errorf("unable to resolve credentials: %w", err)
 	}
-	defer f.Close()
+	schedulerService.SetAccount(creds.Username)
 
-	scanner := bufio.NewScanner(f)
-	if scanner.Scan() {
-		username = scanner.Text()
+	return store, nil
+}
+
+// login resolves the credentials reference through the secret store, the
+// same way the lambda does when a trigger fires, and logs in with them.
+func login(cfaService *cfa.Service, store secrets.Store, ref string) (secrets.Credentials, error) {
+	creds, err := store.Credentials(ref)
+	if err != nil {
+		return secrets.Credentials{}, fmt.Errorf("unable to resolve credentials: %w", err)
 	}
-	if scanner.Scan() {
-		password = scanner.Text()
+	if _, err := cfaService.Login(creds.Username, creds.Password); err != nil {
+		return secrets.Credentials{}, fmt.Errorf("unable to login: %w", err)
 	}
 
-	if username == "" || password == "" {
-		return fmt.Errorf("unable to read username or password")
+	return creds, nil
+}
+
+func getAWSSession(cfg *config.Config) (*session.Session, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWSRegion),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new session: %w", err)
 	}
 
-	return nil
+	return sess, nil
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/deadletter"
+	"github.com/itsHabib/rsvper/internal/jobstore"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+// replayCmd re-runs failed task requests locally, from the dead letter queue,
+// a saved payload file or a failed rsvperd job.
+func replayCmd(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	configPath := fs.String("config", "", "path to the config file, defaults to $RSVPER_CONFIG or "+config.DefaultPath)
+	file := fs.String("file", "", "replay the task request saved in this file")
+	dlq := fs.Bool("dlq", false, "replay task requests from the configured dead letter queue")
+	max := fs.Int("max", 10, "most dead letter messages to replay")
+	job := fs.String("job", "", "replay this failed rsvperd job")
+	store := fs.String("store", jobStorePath, "path to the rsvperd job store, used with -job")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: scheduler replay -file payload.json | -dlq [-max n] | -job name [-store path]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var sources int
+	for _, set := range []bool{*file != "", *dlq, *job != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one of -file, -dlq or -job")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("unable to load config: %w", err)
+	}
+	secretStore, err := secrets.Open(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to open secret store: %w", err)
+	}
+	notifier, err := notify.FromConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to create notifiers: %w", err)
+	}
+	booker, err := booking.NewBooker(secretStore, notifier)
+	if err != nil {
+		return fmt.Errorf("unable to create booker: %w", err)
+	}
+	// replays share the lambda's ledger so a replayed outcome isn't sent
+	// twice
+	ledger, err := booking.OpenLedger(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to open idempotency ledger: %w", err)
+	}
+	booker.SetLedger(ledger)
+
+	switch {
+	case *file != "":
+		task, err := deadletter.LoadFile(*file)
+		if err != nil {
+			return err
+		}
+		return replay(booker, task)
+	case *job != "":
+		return replayJob(booker, *store, *job)
+	default:
+		return replayDeadLetters(booker, cfg, *max)
+	}
+}
+
+func replayDeadLetters(booker *booking.Booker, cfg *config.Config, max int) error {
+	if cfg.DeadLetterARN == "" {
+		return fmt.Errorf("missing config: deadLetterArn")
+	}
+	sess, err := getAWSSession(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to get aws session: %w", err)
+	}
+	queue, err := deadletter.NewQueue(sess, cfg.DeadLetterARN)
+	if err != nil {
+		return err
+	}
+	messages, err := queue.Receive(max)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("received %d dead letter message(s)\n", len(messages))
+
+	var failed int
+	for _, msg := range messages {
+		fmt.Printf("message %s failed with: %s\n", msg.ID, msg.Error)
+		err := replay(booker, msg.Task)
+		var skipped *notReplayableError
+		switch {
+		case errors.As(err, &skipped):
+			// the class can't be booked anymore, the message is of no use
+			fmt.Printf("dropping message %s: %v\n", msg.ID, err)
+		case err != nil:
+			failed++
+			fmt.Printf("unable to replay message %s, leaving it on the queue: %v\n", msg.ID, err)
+			continue
+		}
+		if err := queue.Delete(msg); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("unable to replay %d message(s)", failed)
+	}
+
+	return nil
+}
+
+func replayJob(booker *booking.Booker, storePath, name string) error {
+	store, err := jobstore.Open(storePath)
+	if err != nil {
+		return fmt.Errorf("unable to open job store: %w", err)
+	}
+	jobs, err := store.Jobs()
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Trigger.Name != name {
+			continue
+		}
+		if job.Status != jobstore.StatusFailed {
+			return fmt.Errorf("job %s is %s, only failed jobs can be replayed", name, job.Status)
+		}
+		res, err := bookTask(booker, job.Trigger.Task)
+		if err != nil {
+			return err
+		}
+		return store.Finish(name, jobstore.StatusDone, res.Status, nil)
+	}
+
+	return fmt.Errorf("job %s not found", name)
+}
+
+func replay(booker *booking.Booker, task scheduler.TaskRequest) error {
+	_, err := bookTask(booker, task)
+	return err
+}
+
+// bookTask books the task's class if it can still be booked.
+func bookTask(booker *booking.Booker, task scheduler.TaskRequest) (*booking.Result, error) {
+	class := task.Schedule
+	if err := replayable(class, time.Now()); err != nil {
+		return nil, err
+	}
+	fmt.Printf("replaying class %s at %s\n", classTitle(class), formatStart(class))
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfa.MaxLeadTime+5*time.Minute)
+	defer cancel()
+	res, err := booker.Run(ctx, task)
+	fmt.Printf("result: %s\n", res.JSON())
+	if err != nil {
+		return nil, fmt.Errorf("unable to book class %d: %w", class.ID, err)
+	}
+	fmt.Printf("replayed class %s: %s\n", classTitle(class), res.Status)
+
+	return res, nil
+}
+
+// notReplayableError is returned for classes that can't be booked anymore,
+// or not yet.
+type notReplayableError struct {
+	reason string
+}
+
+func (e *notReplayableError) Error() string {
+	return e.reason
+}
+
+// replayable checks that the class hasn't started and that its rsvp window
+// opens soon enough for a booking to wait for it.
+func replayable(class cfa.Schedule, now time.Time) error {
+	if class.Start == nil {
+		return &notReplayableError{reason: "class has no start time"}
+	}
+	if !class.Start.After(now) {
+		return &notReplayableError{reason: fmt.Sprintf("class %d already started", class.ID)}
+	}
+	if windowOpen := class.Start.Add(-cfa.MinimumRSVPTime); windowOpen.Sub(now) > cfa.MaxLeadTime {
+		return fmt.Errorf("rsvp window for class %d opens at %s, too early to replay, plan it with scheduler run instead", class.ID, windowOpen.Format(time.RFC3339))
+	}
+
+	return nil
+}
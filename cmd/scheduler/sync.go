@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+// syncCmd treats the requests as the desired state: it plans the triggers
+// they need, deletes pending triggers that aren't requested anymore, and
+// asks before changing anything.
+func syncCmd(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	pf := addPlanFlags(fs)
+	yes := fs.Bool("yes", false, "apply without asking for confirmation")
+	fs.Parse(args)
+
+	pf.prune = true
+	schedulerService, plan, err := pf.plan()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	plan.WriteSummary(os.Stdout)
+	if !plan.HasChanges() {
+		fmt.Println("\nNo changes. Triggers match the requests.")
+		return nil
+	}
+	fmt.Printf(
+		"\nPlan: %d to create, %d to update, %d to delete, %d to book now.\n",
+		plan.Count(scheduler.ActionCreate),
+		plan.Count(scheduler.ActionUpdate),
+		plan.Count(scheduler.ActionDelete),
+		plan.Count(scheduler.ActionBook),
+	)
+	if !*yes && !confirm("\nApply these changes? Only 'yes' will be accepted: ") {
+		fmt.Println("Sync cancelled, nothing was changed.")
+		return nil
+	}
+
+	applyErr := schedulerService.Apply(context.Background(), plan)
+	fmt.Println()
+	plan.WriteSummary(os.Stdout)
+	if applyErr != nil {
+		return fmt.Errorf("unable to apply plan: %w", applyErr)
+	}
+	fmt.Println("Sync complete.")
+
+	return nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	return strings.TrimSpace(answer) == "yes"
+}
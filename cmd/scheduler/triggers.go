@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+// triggerView is what's shown for a trigger.
+type triggerView struct {
+	Name        string       `json:"name"`
+	ID          string       `json:"id,omitempty"`
+	Description string       `json:"description,omitempty"`
+	State       string       `json:"state,omitempty"`
+	Time        time.Time    `json:"time"`
+	Class       cfa.Schedule `json:"class"`
+	Credentials string       `json:"credentials,omitempty"`
+}
+
+func newTriggerView(t scheduler.Trigger) triggerView {
+	return triggerView{
+		Name:        t.Name,
+		ID:          t.ID,
+		Description: t.Description,
+		State:       t.State,
+		Time:        t.Time,
+		Class:       t.Task.Schedule,
+		Credentials: t.Task.Credentials,
+	}
+}
+
+func listCmd(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	all := fs.Bool("all", false, "include triggers that already fired")
+	asJSON := fs.Bool("json", false, "print triggers as json")
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	_, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	var triggers []scheduler.Trigger
+	if *all {
+		triggers, err = schedulerService.ListTriggers()
+	} else {
+		triggers, err = schedulerService.PendingTriggers(time.Now())
+	}
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		views := make([]triggerView, len(triggers))
+		for i := range triggers {
+			views[i] = newTriggerView(triggers[i])
+		}
+		return printJSON(views)
+	}
+	if len(triggers) == 0 {
+		fmt.Println("no triggers")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTRIGGER\tCLASS\tCLASS START\tSTATE")
+	for _, t := range triggers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, t.Time.Format(time.RFC3339), classTitle(t.Task.Schedule), formatStart(t.Task.Schedule), t.State)
+	}
+
+	return w.Flush()
+}
+
+func showCmd(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	asJSON := fs.Bool("json", false, "print the trigger as json")
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return fmt.Errorf("expected the name of the trigger to show")
+	}
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	_, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	t, err := schedulerService.GetTrigger(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(newTriggerView(*t))
+	}
+	class := t.Task.Schedule
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "name:\t%s\n", t.Name)
+	fmt.Fprintf(w, "id:\t%s\n", t.ID)
+	fmt.Fprintf(w, "state:\t%s\n", t.State)
+	fmt.Fprintf(w, "trigger:\t%s\n", t.Time.Format(time.RFC3339))
+	fmt.Fprintf(w, "rsvp window opens:\t%s\n", formatWindowOpen(class))
+	fmt.Fprintf(w, "class:\t%s\n", classTitle(class))
+	fmt.Fprintf(w, "class id:\t%d\n", class.ID)
+	fmt.Fprintf(w, "class start:\t%s\n", formatStart(class))
+	if class.End != nil {
+		fmt.Fprintf(w, "class end:\t%s\n", class.End.Format(time.RFC3339))
+	}
+	fmt.Fprintf(w, "coaches:\t%s\n", class.Coaches)
+	fmt.Fprintf(w, "url:\t%s\n", class.URL)
+	fmt.Fprintf(w, "credentials:\t%s\n", t.Task.Credentials)
+
+	return w.Flush()
+}
+
+func cancelCmd(args []string) error {
+	fs := flag.NewFlagSet("cancel", flag.ExitOnError)
+	class := fs.String("class", "", "cancel triggers for classes whose title contains this text")
+	date := fs.String("date", "", "cancel triggers for classes on this day, yyyy-mm-dd")
+	common := addCommonFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: scheduler cancel [-class text] [-date yyyy-mm-dd] [trigger name]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *class == "" && *date == "" && fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected a trigger name, -class or -date")
+	}
+
+	var matchers []func(scheduler.Trigger) bool
+	if *class != "" {
+		matchers = append(matchers, scheduler.MatchClass(*class))
+	}
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *date != "" {
+		loc := cfg.Location()
+		day, err := time.ParseInLocation("2006-01-02", *date, loc)
+		if err != nil {
+			return fmt.Errorf("invalid date %q, expected yyyy-mm-dd", *date)
+		}
+		matchers = append(matchers, scheduler.MatchDate(day, loc))
+	}
+	if fs.NArg() > 0 {
+		names := make(map[string]bool)
+		for _, name := range fs.Args() {
+			names[name] = true
+		}
+		matchers = append(matchers, func(t scheduler.Trigger) bool { return names[t.Name] })
+	}
+
+	_, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := setAccount(cfg, schedulerService); err != nil {
+		return err
+	}
+	now := time.Now()
+	cancelled, err := schedulerService.CancelTriggers(func(t scheduler.Trigger) bool {
+		if !t.Pending(now) {
+			return false
+		}
+		for _, match := range matchers {
+			if !match(t) {
+				return false
+			}
+		}
+		return true
+	})
+	printTriggers("cancelled", cancelled)
+
+	return err
+}
+
+func purgeCmd(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	_, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := setAccount(cfg, schedulerService); err != nil {
+		return err
+	}
+	purged, err := schedulerService.PurgeTriggers(time.Now())
+	printTriggers("purged", purged)
+
+	return err
+}
+
+func gcCmd(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ExitOnError)
+	common := addCommonFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	_, schedulerService, err := common.newServices(cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := setAccount(cfg, schedulerService); err != nil {
+		return err
+	}
+	removed, err := schedulerService.CollectGarbage(time.Now())
+	printTriggers("removed", removed)
+
+	return err
+}
+
+func printTriggers(action string, triggers []scheduler.Trigger) {
+	fmt.Printf("%s %d trigger(s)\n", action, len(triggers))
+	for _, t := range triggers {
+		fmt.Printf("  - %s, class %s at %s\n", t.Name, classTitle(t.Task.Schedule), formatStart(t.Task.Schedule))
+	}
+}
+
+func formatStart(class cfa.Schedule) string {
+	if class.Start == nil {
+		return "unknown"
+	}
+
+	return class.Start.Format(time.RFC3339)
+}
+
+func formatWindowOpen(class cfa.Schedule) string {
+	if class.Start == nil {
+		return "unknown"
+	}
+
+	return class.Start.Add(-cfa.MinimumRSVPTime).Format(time.RFC3339)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
@@ -3,9 +3,11 @@ module github.com/itsHabib/rsvper
 go 1.18
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/aws/aws-lambda-go v1.38.0
-	github.com/aws/aws-sdk-go v1.44.219
+	github.com/aws/aws-sdk-go v1.55.8
 	github.com/twilio/twilio-go v1.3.5
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
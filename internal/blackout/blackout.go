@@ -0,0 +1,160 @@
+package blackout
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+const dateLayout = "2006-01-02"
+
+// Period is a span of time where no classes should be booked, e.g. while
+// traveling. Start is inclusive and End is exclusive.
+type Period struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.Start) && t.Before(p.End)
+}
+
+func (p Period) String() string {
+	s := fmt.Sprintf("%s to %s", p.Start.Format(time.RFC3339), p.End.Format(time.RFC3339))
+	if p.Reason != "" {
+		s += " (" + p.Reason + ")"
+	}
+
+	return s
+}
+
+// Periods is a list of blackout periods. It implements scheduler.Filter,
+// skipping classes that start inside any of the periods.
+type Periods []Period
+
+// Find returns the first period containing t.
+func (p Periods) Find(t time.Time) (Period, bool) {
+	for i := range p {
+		if p[i].Contains(t) {
+			return p[i], true
+		}
+	}
+
+	return Period{}, false
+}
+
+func (p Periods) Filter(sched cfa.Schedule) (scheduler.Verdict, string, error) {
+	if sched.Start == nil {
+		return scheduler.Allow, "", nil
+	}
+	if period, ok := p.Find(*sched.Start); ok {
+		return scheduler.Skip, "inside blackout " + period.String(), nil
+	}
+
+	return scheduler.Allow, "", nil
+}
+
+// NewPeriod creates a period from two bounds, each either a date in
+// yyyy-mm-dd format or an RFC3339 time. Dates are interpreted in loc and an
+// end date includes the whole day.
+func NewPeriod(from, to, reason string, loc *time.Location) (Period, error) {
+	start, err := parseBound(from, loc, false)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := parseBound(to, loc, true)
+	if err != nil {
+		return Period{}, fmt.Errorf("invalid end: %w", err)
+	}
+	if !end.After(start) {
+		return Period{}, fmt.Errorf("end %s must be after start %s", end.Format(time.RFC3339), start.Format(time.RFC3339))
+	}
+
+	return Period{Start: start, End: end, Reason: reason}, nil
+}
+
+func parseBound(text string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, text); err == nil {
+		return t, nil
+	}
+	day, err := time.ParseInLocation(dateLayout, text, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a yyyy-mm-dd date or an RFC3339 time", text)
+	}
+	if end {
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return day, nil
+}
+
+type file struct {
+	Blackouts Periods `json:"blackouts"`
+}
+
+// Load reads the blackout periods from the file at path. A missing file means
+// there are no blackouts.
+func Load(path string) (Periods, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read blackouts file: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Parse decodes the contents of a blackouts file.
+func Parse(data []byte) (Periods, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unable to decode blackouts file: %w", err)
+	}
+	for i := range f.Blackouts {
+		if !f.Blackouts[i].End.After(f.Blackouts[i].Start) {
+			return nil, fmt.Errorf("blackout %d: end must be after start", i)
+		}
+	}
+
+	return f.Blackouts, nil
+}
+
+// Save writes the blackout periods to the file at path, sorted by start. The
+// file is replaced atomically.
+func Save(path string, periods Periods) error {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].Start.Before(periods[j].Start)
+	})
+	data, err := json.MarshalIndent(file{Blackouts: periods}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode blackouts: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write blackouts: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write blackouts: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("unable to replace blackouts file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+// Package booking runs the booking path shared by the rsvp lambda and the
+// rsvperd daemon: log in, poll until the class's rsvp window opens, register,
+// and notify about the outcome.
+package booking
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+// Booker runs task requests, resolving their credentials from a secret store
+// and sending the outcome to a notifier. Running the same task more than
+// once is safe: classes already booked aren't booked again, and each outcome
+// is only sent once per task, as far as the ledger remembers.
+type Booker struct {
+	secrets  secrets.Store
+	notifier notify.Notifier
+	ledger   Ledger
+}
+
+func NewBooker(store secrets.Store, notifier notify.Notifier) (*Booker, error) {
+	if store == nil {
+		return nil, fmt.Errorf("secret store cannot be nil")
+	}
+	if notifier == nil {
+		return nil, fmt.Errorf("notifier cannot be nil")
+	}
+
+	return &Booker{secrets: store, notifier: notifier, ledger: NewMemoryLedger()}, nil
+}
+
+// SetLedger replaces the in memory ledger, e.g. with one shared by every
+// lambda invocation.
+func (b *Booker) SetLedger(l Ledger) {
+	b.ledger = l
+}
+
+// Book runs a task request and returns the final rsvp status. It logs in
+// with a fresh session before polling, since triggers fire shortly before the
+// rsvp window opens.
+func (b *Booker) Book(ctx context.Context, task scheduler.TaskRequest) (cfa.RSVPStatus, error) {
+	res, err := b.Run(ctx, task)
+
+	return res.status, err
+}
+
+// Run books like Book and returns a result describing the booking. The
+// result is filled in whether or not the booking failed.
+func (b *Booker) Run(ctx context.Context, task scheduler.TaskRequest) (*Result, error) {
+	res := newResult(task)
+	var trace cfa.Trace
+	status, alreadyBooked, err := b.book(ctx, task, &trace)
+	res.finish(status, &trace, err, time.Now())
+	res.AlreadyBooked = alreadyBooked
+	if alreadyBooked {
+		// an earlier invocation or the account's owner booked it, either
+		// way there's nothing new to tell, unless a waitlisted spot turned
+		// into an rsvp since
+		fmt.Printf("class %d is already %s, nothing to do\n", task.Schedule.ID, status)
+		if status == cfa.RSVPED {
+			res.Notified = b.NotifyPromotion(ctx, task)
+		}
+		return res, nil
+	}
+
+	event := notify.Event{
+		Class:    task.Schedule,
+		Account:  task.Account,
+		Attempts: res.RegisterAttempts,
+	}
+	if res.WindowOpen != nil && len(trace.Attempts) > 0 {
+		event.Latency = res.Finished.Sub(*res.WindowOpen)
+	}
+	switch {
+	case err != nil:
+		event.Kind = notify.KindFailed
+		event.Error = err.Error()
+	case status == cfa.WAITLISTED:
+		event.Kind = notify.KindWaitlisted
+		event.Status = status.String()
+	default:
+		event.Kind = notify.KindBooked
+		event.Status = status.String()
+	}
+	res.Notified = b.notify(ctx, task, event)
+
+	return res, err
+}
+
+// NotifyPromotion sends a promoted event for a class the account now has an
+// rsvp for, if it was sent a waitlisted notification for it before. It
+// reports whether the event was sent, which happens once per class.
+func (b *Booker) NotifyPromotion(ctx context.Context, task scheduler.TaskRequest) bool {
+	waitlisted, err := b.ledger.Seen(ctx, notificationKey(task, notify.KindWaitlisted))
+	if err != nil {
+		fmt.Printf("unable to check for a waitlisted notification: %v\n", err)
+		return false
+	}
+	if !waitlisted {
+		return false
+	}
+
+	return b.notify(ctx, task, notify.Event{
+		Kind:    notify.KindPromoted,
+		Class:   task.Schedule,
+		Account: task.Account,
+		Status:  cfa.RSVPED.String(),
+	})
+}
+
+// notify sends the event unless the same outcome was already sent for the
+// task, and reports whether it was sent. A failed send gives up its claim so
+// a retry or replay sends it again, at the risk of a duplicate when only some
+// notifiers failed.
+func (b *Booker) notify(ctx context.Context, task scheduler.TaskRequest, event notify.Event) bool {
+	key := notificationKey(task, event.Kind)
+	expires := time.Now().Add(ledgerRetention)
+	if start := task.Schedule.Start; start != nil {
+		expires = start.Add(ledgerRetention)
+	}
+	claimed, err := b.ledger.Claim(ctx, key, expires)
+	if err != nil {
+		// rather send a duplicate than nothing
+		fmt.Printf("unable to check for duplicate notification: %v\n", err)
+		claimed = true
+	}
+	if !claimed {
+		fmt.Printf("%s notification for %s was already sent, skipping\n", event.Kind, key)
+		return false
+	}
+
+	if err := b.notifier.Notify(ctx, event); err != nil {
+		fmt.Printf("unable to send notification: %v\n", err)
+		// the send may have failed because ctx ran out, don't let that keep
+		// the claim too
+		releaseCtx, cancel := context.WithTimeout(context.Background(), releaseTimeout)
+		defer cancel()
+		if err := b.ledger.Release(releaseCtx, key); err != nil {
+			fmt.Printf("unable to release notification claim: %v\n", err)
+		}
+		return false
+	}
+
+	return true
+}
+
+// notificationKey is the ledger key of a task's notification of kind.
+func notificationKey(task scheduler.TaskRequest, kind notify.Kind) string {
+	return task.Key() + "/" + string(kind)
+}
+
+// book logs in and polls until the class is booked. It first checks whether
+// the account already has a spot, reporting true when it does.
+func (b *Booker) book(ctx context.Context, task scheduler.TaskRequest, trace *cfa.Trace) (cfa.RSVPStatus, bool, error) {
+	if task.Credentials == "" {
+		return 0, false, &bookingError{ErrorCredentials, fmt.Errorf("task has no credentials reference")}
+	}
+	creds, err := b.secrets.Credentials(task.Credentials)
+	if err != nil {
+		return 0, false, &bookingError{ErrorCredentials, fmt.Errorf("unable to resolve credentials: %w", err)}
+	}
+
+	c := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	s, err := cfa.NewService(c)
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to create cfa service: %w", err)
+	}
+	s.SetTrace(trace)
+
+	if _, err := s.Login(creds.Username, creds.Password); err != nil {
+		return 0, false, &bookingError{ErrorLogin, fmt.Errorf("unable to login: %w", err)}
+	}
+	fmt.Println("successfully logged in")
+
+	// a duplicate invocation finds the class already booked
+	switch status, err := s.CheckRSVP(task.Schedule); {
+	case err != nil:
+		fmt.Printf("unable to check rsvp status before polling, polling anyway: %v\n", err)
+	case status == cfa.RSVPED || status == cfa.WAITLISTED:
+		return status, true, nil
+	}
+
+	// stop polling early enough to still send the outcome before the
+	// invocation's deadline
+	pollCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		pollCtx, cancel = context.WithDeadline(ctx, deadline.Add(-notifyReserve))
+		defer cancel()
+	}
+	status, err := s.PollRSVP(pollCtx, task.Schedule)
+	if err != nil {
+		return 0, false, &bookingError{pollCategory(err), fmt.Errorf("unable to poll rsvp: %w", err)}
+	}
+	fmt.Printf("rsvp status: %s\n", status.String())
+
+	return status, false, nil
+}
@@ -0,0 +1,83 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+)
+
+type fakeStore struct{}
+
+func (fakeStore) Credentials(string) (secrets.Credentials, error) {
+	return secrets.Credentials{}, secrets.ErrNotFound
+}
+
+type fakeNotifier struct {
+	err  error
+	sent []notify.Event
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, e notify.Event) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.sent = append(n.sent, e)
+
+	return nil
+}
+
+func TestNotifyOnlyOnce(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	task := scheduler.TaskRequest{Schedule: cfa.Schedule{ID: 1, Start: &start}, Account: "me@example.com"}
+	event := notify.Event{Kind: notify.KindBooked, Class: task.Schedule}
+	notifier := &fakeNotifier{err: errors.New("twilio is down")}
+	b, err := NewBooker(fakeStore{}, notifier)
+	if err != nil {
+		t.Fatalf("unable to create booker: %v", err)
+	}
+
+	if b.notify(context.Background(), task, event) {
+		t.Fatal("got notified with a failing notifier")
+	}
+	// the failed send doesn't count, the retry sends it
+	notifier.err = nil
+	if !b.notify(context.Background(), task, event) {
+		t.Fatal("got no notification after the notifier recovered")
+	}
+	if b.notify(context.Background(), task, event) {
+		t.Fatal("got a duplicate notification")
+	}
+	if len(notifier.sent) != 1 {
+		t.Errorf("got %d notifications, want 1", len(notifier.sent))
+	}
+}
+
+func TestNotifyPromotion(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	task := scheduler.TaskRequest{Schedule: cfa.Schedule{ID: 1, Start: &start}, Account: "me@example.com"}
+	notifier := &fakeNotifier{}
+	b, err := NewBooker(fakeStore{}, notifier)
+	if err != nil {
+		t.Fatalf("unable to create booker: %v", err)
+	}
+
+	if b.NotifyPromotion(context.Background(), task) {
+		t.Fatal("got a promotion for a class that was never waitlisted")
+	}
+	b.notify(context.Background(), task, notify.Event{Kind: notify.KindWaitlisted, Class: task.Schedule})
+	if !b.NotifyPromotion(context.Background(), task) {
+		t.Fatal("got no promotion for a waitlisted class")
+	}
+	if b.NotifyPromotion(context.Background(), task) {
+		t.Fatal("got a duplicate promotion")
+	}
+	if len(notifier.sent) != 2 || notifier.sent[1].Kind != notify.KindPromoted {
+		t.Errorf("got %+v, want a waitlisted then a promoted event", notifier.sent)
+	}
+}
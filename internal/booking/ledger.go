@@ -0,0 +1,169 @@
+package booking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"github.com/itsHabib/rsvper/internal/config"
+)
+
+// Ledger remembers which notifications were sent, so duplicate invocations
+// of a task don't send the same outcome twice.
+type Ledger interface {
+	// Claim records key and reports whether it was new. Keys can be
+	// forgotten after expires.
+	Claim(ctx context.Context, key string, expires time.Time) (bool, error)
+	// Release forgets a claimed key, e.g. when sending failed, so the next
+	// invocation tries again.
+	Release(ctx context.Context, key string) error
+	// Seen reports whether key is claimed and hasn't expired.
+	Seen(ctx context.Context, key string) (bool, error)
+}
+
+// OpenLedger returns the DynamoDB ledger when an idempotency table is
+// configured, and an in memory ledger otherwise.
+func OpenLedger(cfg *config.Config) (Ledger, error) {
+	if cfg.IdempotencyTable == "" {
+		return NewMemoryLedger(), nil
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(cfg.AWSRegion),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new session: %w", err)
+	}
+
+	return NewDynamoDBLedger(sess, cfg.IdempotencyTable)
+}
+
+// MemoryLedger only dedupes within the process, which is enough for rsvperd.
+type MemoryLedger struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}
+
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{expires: make(map[string]time.Time)}
+}
+
+func (l *MemoryLedger) Claim(_ context.Context, key string, expires time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range l.expires {
+		if now.After(exp) {
+			delete(l.expires, k)
+		}
+	}
+	if _, ok := l.expires[key]; ok {
+		return false, nil
+	}
+	l.expires[key] = expires
+
+	return true, nil
+}
+
+func (l *MemoryLedger) Release(_ context.Context, key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.expires, key)
+
+	return nil
+}
+
+func (l *MemoryLedger) Seen(_ context.Context, key string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	exp, ok := l.expires[key]
+
+	return ok && time.Now().Before(exp), nil
+}
+
+// DynamoDBLedger keeps claims in a DynamoDB table so every lambda invocation
+// sees them. The table's partition key is the string attribute "key", and
+// its ttl attribute should be set to "expires".
+type DynamoDBLedger struct {
+	client *dynamodb.DynamoDB
+	table  string
+}
+
+func NewDynamoDBLedger(sess *session.Session, table string) (*DynamoDBLedger, error) {
+	if sess == nil {
+		return nil, fmt.Errorf("aws session cannot be nil")
+	}
+	if table == "" {
+		return nil, fmt.Errorf("idempotency table cannot be empty")
+	}
+
+	return &DynamoDBLedger{client: dynamodb.New(sess), table: table}, nil
+}
+
+func (l *DynamoDBLedger) Claim(ctx context.Context, key string, expires time.Time) (bool, error) {
+	_, err := l.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"key":     {S: aws.String(key)},
+			"expires": {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+		},
+		ConditionExpression:      aws.String("attribute_not_exists(#k)"),
+		ExpressionAttributeNames: map[string]*string{"#k": aws.String("key")},
+	})
+	var aerr awserr.Error
+	if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to claim %s: %w", key, err)
+	}
+
+	return true, nil
+}
+
+func (l *DynamoDBLedger) Release(ctx context.Context, key string) error {
+	_, err := l.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to release %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func (l *DynamoDBLedger) Seen(ctx context.Context, key string) (bool, error) {
+	out, err := l.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(l.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"key": {S: aws.String(key)},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to look up %s: %w", key, err)
+	}
+	if out.Item == nil {
+		return false, nil
+	}
+	// the ttl deletes expired items lazily
+	if exp, ok := out.Item["expires"]; ok && exp.N != nil {
+		unix, err := strconv.ParseInt(*exp.N, 10, 64)
+		if err == nil && time.Now().After(time.Unix(unix, 0)) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
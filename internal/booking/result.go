@@ -0,0 +1,170 @@
+package booking
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+// Error categories, for telling failures apart without parsing messages.
+const (
+	// ErrorCredentials means the task's credentials couldn't be resolved.
+	ErrorCredentials = "credentials"
+	// ErrorLogin means logging in to the gym's site failed.
+	ErrorLogin = "login"
+	// ErrorTimeout means polling ran out of time before registering.
+	ErrorTimeout = "timeout"
+	// ErrorTooEarly means the task started too long before the rsvp window
+	// opened to register within its deadline.
+	ErrorTooEarly = "too_early"
+	// ErrorCancelled means the booking was stopped, e.g. on shutdown.
+	ErrorCancelled = "cancelled"
+	// ErrorNotRegistered means every register attempt left the account
+	// unregistered.
+	ErrorNotRegistered = "not_registered"
+	// ErrorRequest means a request to the gym's site failed.
+	ErrorRequest = "request"
+	// ErrorInternal is anything else.
+	ErrorInternal = "internal"
+)
+
+const (
+	// ledgerRetention is how long after a class starts its notifications
+	// are remembered.
+	ledgerRetention = 24 * time.Hour
+	// notifyReserve is how much of a booking's deadline is kept for sending
+	// the outcome once polling stops.
+	notifyReserve = 30 * time.Second
+	// releaseTimeout bounds giving up a notification claim after sending
+	// failed.
+	releaseTimeout = 5 * time.Second
+)
+
+// Result describes how a booking went. The rsvp lambda returns it and every
+// runner logs it as a single json line.
+type Result struct {
+	ClassID int        `json:"classId"`
+	Title   string     `json:"title"`
+	Start   *time.Time `json:"start,omitempty"`
+	Account string     `json:"account,omitempty"`
+	// Status is the final rsvp status, or failed.
+	Status string `json:"status"`
+	// RegisterAttempts is how many register requests were made, each of
+	// them listed in Attempts.
+	RegisterAttempts int           `json:"registerAttempts"`
+	Attempts         []cfa.Attempt `json:"attempts"`
+	// WindowOpen is when the class's rsvp window opened.
+	WindowOpen *time.Time `json:"windowOpen,omitempty"`
+	// WindowOpenDriftMs is how long after the window opened the first
+	// register request was made. It's negative if it was made early.
+	WindowOpenDriftMs *int64 `json:"windowOpenDriftMs,omitempty"`
+	// Sessions are the logins made, including refreshes of a session that
+	// stopped working.
+	Sessions      []cfa.SessionEvent `json:"sessions"`
+	Error         string             `json:"error,omitempty"`
+	ErrorCategory string             `json:"errorCategory,omitempty"`
+	// IdempotencyKey identifies the task across duplicate invocations.
+	IdempotencyKey string `json:"idempotencyKey"`
+	// AlreadyBooked is set when the account already had a spot, so nothing
+	// was registered. Only a promotion off the wait list is sent then.
+	AlreadyBooked bool `json:"alreadyBooked,omitempty"`
+	// Notified is whether the outcome was sent, it isn't for duplicates.
+	Notified bool `json:"notified"`
+
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+
+	status cfa.RSVPStatus
+}
+
+func newResult(task scheduler.TaskRequest) *Result {
+	class := task.Schedule
+	res := Result{
+		ClassID:        class.ID,
+		Title:          notify.ClassTitle(class),
+		Start:          class.Start,
+		Account:        task.Account,
+		Started:        time.Now(),
+		IdempotencyKey: task.Key(),
+	}
+	if class.Start != nil {
+		windowOpen := class.Start.Add(-cfa.MinimumRSVPTime)
+		res.WindowOpen = &windowOpen
+	}
+
+	return &res
+}
+
+func (r *Result) finish(status cfa.RSVPStatus, trace *cfa.Trace, err error, now time.Time) {
+	r.Finished = now
+	// empty lists rather than nulls keep the payload's shape stable
+	r.Attempts = append([]cfa.Attempt{}, trace.Attempts...)
+	r.RegisterAttempts = len(trace.Attempts)
+	r.Sessions = append([]cfa.SessionEvent{}, trace.Sessions...)
+	if r.WindowOpen != nil && len(trace.Attempts) > 0 {
+		drift := trace.Attempts[0].Time.Sub(*r.WindowOpen).Milliseconds()
+		r.WindowOpenDriftMs = &drift
+	}
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+		r.ErrorCategory = Category(err)
+		return
+	}
+	r.status = status
+	r.Status = status.String()
+}
+
+// JSON returns the result as a single line of json.
+func (r *Result) JSON() string {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(data)
+}
+
+// bookingError tags a booking error with its category.
+type bookingError struct {
+	category string
+	err      error
+}
+
+func (e *bookingError) Error() string {
+	return e.err.Error()
+}
+
+func (e *bookingError) Unwrap() error {
+	return e.err
+}
+
+// Category returns the category of an error returned by Book or Run.
+func Category(err error) string {
+	var be *bookingError
+	if errors.As(err, &be) {
+		return be.category
+	}
+
+	return ErrorInternal
+}
+
+func pollCategory(err error) string {
+	switch {
+	case errors.Is(err, cfa.ErrTooEarly):
+		return ErrorTooEarly
+	case errors.Is(err, cfa.ErrPollTimeout), errors.Is(err, context.DeadlineExceeded):
+		return ErrorTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorCancelled
+	case errors.Is(err, cfa.ErrNotRegistered):
+		return ErrorNotRegistered
+	default:
+		return ErrorRequest
+	}
+}
@@ -0,0 +1,237 @@
+// Package calendar detects conflicts between classes and busy events from
+// local ics calendar files.
+package calendar
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+// defaultClassLength is used for classes without an end time.
+const defaultClassLength = time.Hour
+
+// maxOccurrences caps how many occurrences of a recurring event are expanded
+// when looking for an overlap.
+const maxOccurrences = 100000
+
+type Mode string
+
+const (
+	// ModeSkip doesn't schedule classes that conflict with an event.
+	ModeSkip Mode = "skip"
+	// ModeFlag schedules conflicting classes with a warning.
+	ModeFlag Mode = "flag"
+)
+
+// Load parses every ics file at the given paths. Directories are searched
+// recursively for files with an .ics extension. Warnings are prefixed with
+// the file they come from.
+func Load(paths []string, loc *time.Location) ([]Event, []error, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to stat %s: %w", path, err)
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ".ics") {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to search %s for calendars: %w", path, err)
+		}
+	}
+
+	var (
+		events   []Event
+		warnings []error
+	)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to open calendar: %w", err)
+		}
+		parsed, parseWarnings, err := Parse(f, loc)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to parse calendar %s: %w", file, err)
+		}
+		events = append(events, parsed...)
+		for _, w := range parseWarnings {
+			warnings = append(warnings, fmt.Errorf("%s: %w", file, w))
+		}
+	}
+
+	return events, warnings, nil
+}
+
+// Conflicts checks classes against busy events. It implements
+// scheduler.Filter.
+type Conflicts struct {
+	events []Event
+	buffer time.Duration
+	mode   Mode
+}
+
+// NewConflicts creates a conflict checker. The buffer is added before and
+// after each class, e.g. for commute time.
+func NewConflicts(events []Event, buffer time.Duration, mode Mode) (*Conflicts, error) {
+	if buffer < 0 {
+		return nil, fmt.Errorf("buffer cannot be negative")
+	}
+	if mode != ModeSkip && mode != ModeFlag {
+		return nil, fmt.Errorf("invalid mode %q, expected %s or %s", mode, ModeSkip, ModeFlag)
+	}
+
+	return &Conflicts{events: events, buffer: buffer, mode: mode}, nil
+}
+
+// Find returns the events that overlap the class, including the buffer.
+func (c *Conflicts) Find(sched cfa.Schedule) []Event {
+	if sched.Start == nil {
+		return nil
+	}
+	end := sched.Start.Add(defaultClassLength)
+	if sched.End != nil && sched.End.After(*sched.Start) {
+		end = *sched.End
+	}
+	from, to := sched.Start.Add(-c.buffer), end.Add(c.buffer)
+
+	var found []Event
+	for i := range c.events {
+		if c.events[i].Overlaps(from, to) {
+			found = append(found, c.events[i])
+		}
+	}
+
+	return found
+}
+
+func (c *Conflicts) Filter(sched cfa.Schedule) (scheduler.Verdict, string, error) {
+	found := c.Find(sched)
+	if len(found) == 0 {
+		return scheduler.Allow, "", nil
+	}
+
+	summaries := make([]string, len(found))
+	for i := range found {
+		summaries[i] = fmt.Sprintf("%q", found[i].Summary)
+	}
+	reason := fmt.Sprintf("conflicts with calendar event(s) %s", strings.Join(summaries, ", "))
+	if c.buffer > 0 {
+		reason += fmt.Sprintf(" with a %s buffer", c.buffer)
+	}
+	if c.mode == ModeFlag {
+		return scheduler.Flag, reason, nil
+	}
+
+	return scheduler.Skip, reason, nil
+}
+
+// Overlaps reports whether the event, or any occurrence of a recurring event,
+// overlaps [from, to).
+func (e Event) Overlaps(from, to time.Time) bool {
+	if e.Rule == nil {
+		return overlaps(e.Start, e.End, from, to)
+	}
+
+	length := e.End.Sub(e.Start)
+	var found bool
+	e.occurrences(to, func(start time.Time) bool {
+		if overlaps(start, start.Add(length), from, to) {
+			found = true
+			return false
+		}
+		return true
+	})
+
+	return found
+}
+
+// occurrences calls fn with the start of each occurrence before limit, in
+// order, until fn returns false.
+func (e Event) occurrences(limit time.Time, fn func(time.Time) bool) {
+	rule := e.Rule
+	var count int
+	emit := func(start time.Time) bool {
+		if rule.Count > 0 && count >= rule.Count {
+			return false
+		}
+		if !rule.Until.IsZero() && start.After(rule.Until) {
+			return false
+		}
+		if !start.Before(limit) {
+			return false
+		}
+		// excluded occurrences still count towards COUNT
+		count++
+		if e.excluded(start) {
+			return true
+		}
+		return fn(start)
+	}
+
+	switch rule.Freq {
+	case "DAILY":
+		for i := 0; i < maxOccurrences; i++ {
+			if !emit(e.Start.AddDate(0, 0, i*rule.Interval)) {
+				return
+			}
+		}
+	case "WEEKLY":
+		days := rule.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{e.Start.Weekday()}
+		}
+		// offsets from monday, the default start of the week
+		offsets := make([]int, len(days))
+		for i := range days {
+			offsets[i] = (int(days[i]) + 6) % 7
+		}
+		sort.Ints(offsets)
+		monday := e.Start.AddDate(0, 0, -((int(e.Start.Weekday()) + 6) % 7))
+		for week := 0; week*len(offsets) < maxOccurrences; week++ {
+			weekStart := monday.AddDate(0, 0, 7*week*rule.Interval)
+			for _, offset := range offsets {
+				start := weekStart.AddDate(0, 0, offset)
+				if start.Before(e.Start) {
+					continue
+				}
+				if !emit(start) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e Event) excluded(start time.Time) bool {
+	for i := range e.Exceptions {
+		if e.Exceptions[i].Equal(start) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func overlaps(start, end, from, to time.Time) bool {
+	return start.Before(to) && end.After(from)
+}
@@ -0,0 +1,343 @@
+package calendar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	icsDateTimeLayout = "20060102T150405"
+	icsDateLayout     = "20060102"
+)
+
+// Event is a busy block parsed from a VEVENT.
+type Event struct {
+	Summary string
+	Start   time.Time
+	End     time.Time
+	// Rule is set for recurring events.
+	Rule *Rule
+	// Exceptions are occurrence start times excluded from the rule.
+	Exceptions []time.Time
+}
+
+// Rule is the subset of an RRULE the parser understands: daily and weekly
+// recurrences with an optional interval, count, until and weekday list.
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// property is a single unfolded content line, e.g.
+// DTSTART;TZID=America/Chicago:20261020T170000
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the busy events from an ics calendar. Times without a timezone
+// are interpreted in loc. Events that are cancelled or marked as transparent
+// (free) are ignored. Recurring events whose rule isn't supported keep only
+// their first occurrence, and are returned as warnings for the caller to
+// report.
+func Parse(r io.Reader, loc *time.Location) ([]Event, []error, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		events   []Event
+		warnings []error
+		inEvent  bool
+		nested   int
+		props    []property
+	)
+	for _, line := range lines {
+		switch {
+		case line == "BEGIN:VEVENT":
+			inEvent, nested = true, 0
+			props = props[:0]
+		case line == "END:VEVENT":
+			inEvent = false
+			event, busy, err := toEvent(props, loc, &warnings)
+			if err != nil {
+				return nil, nil, err
+			}
+			if busy {
+				events = append(events, event)
+			}
+		case !inEvent:
+		// properties of nested components such as VALARM are skipped
+		case strings.HasPrefix(line, "BEGIN:"):
+			nested++
+		case strings.HasPrefix(line, "END:"):
+			nested--
+		case nested == 0:
+			props = append(props, parseProperty(line))
+		}
+	}
+
+	return events, warnings, nil
+}
+
+// unfold joins folded content lines, which continue on the next line when it
+// starts with a space or a tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read calendar: %w", err)
+	}
+
+	return lines, nil
+}
+
+func parseProperty(line string) property {
+	prop := property{params: make(map[string]string)}
+	i := strings.Index(line, ":")
+	if i < 0 {
+		prop.name = strings.ToUpper(line)
+		return prop
+	}
+	head, value := line[:i], line[i+1:]
+	parts := strings.Split(head, ";")
+	prop.name = strings.ToUpper(parts[0])
+	for _, param := range parts[1:] {
+		if k, v, ok := strings.Cut(param, "="); ok {
+			prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	prop.value = value
+
+	return prop
+}
+
+// toEvent builds the event from its properties, reporting false for events
+// that aren't busy. Recurrences it can't expand are added to warnings.
+func toEvent(props []property, loc *time.Location, warnings *[]error) (Event, bool, error) {
+	var (
+		event       Event
+		unsupported error
+		duration    time.Duration
+		hasEnd      bool
+		allDay      bool
+	)
+	for _, p := range props {
+		switch p.name {
+		case "SUMMARY":
+			event.Summary = p.value
+		case "STATUS":
+			if strings.EqualFold(p.value, "CANCELLED") {
+				return Event{}, false, nil
+			}
+		case "TRANSP":
+			if strings.EqualFold(p.value, "TRANSPARENT") {
+				return Event{}, false, nil
+			}
+		case "DTSTART":
+			t, date, err := parseTime(p, loc)
+			if err != nil {
+				return Event{}, false, fmt.Errorf("invalid DTSTART %q: %w", p.value, err)
+			}
+			event.Start, allDay = t, date
+		case "DTEND":
+			t, _, err := parseTime(p, loc)
+			if err != nil {
+				return Event{}, false, fmt.Errorf("invalid DTEND %q: %w", p.value, err)
+			}
+			event.End, hasEnd = t, true
+		case "DURATION":
+			d, err := parseDuration(p.value)
+			if err != nil {
+				return Event{}, false, fmt.Errorf("invalid DURATION %q: %w", p.value, err)
+			}
+			duration = d
+		case "RRULE":
+			rule, err := parseRule(p.value, loc)
+			if errors.Is(err, errUnsupportedRule) {
+				// keep the first occurrence rather than failing the whole
+				// calendar over an event we can't expand
+				unsupported = err
+				continue
+			}
+			if err != nil {
+				return Event{}, false, fmt.Errorf("invalid RRULE %q: %w", p.value, err)
+			}
+			event.Rule = rule
+		case "EXDATE":
+			for _, v := range strings.Split(p.value, ",") {
+				t, _, err := parseTime(property{params: p.params, value: v}, loc)
+				if err != nil {
+					return Event{}, false, fmt.Errorf("invalid EXDATE %q: %w", v, err)
+				}
+				event.Exceptions = append(event.Exceptions, t)
+			}
+		}
+	}
+	if event.Start.IsZero() {
+		return Event{}, false, nil
+	}
+
+	switch {
+	case hasEnd:
+	case duration > 0:
+		event.End = event.Start.Add(duration)
+	case allDay:
+		event.End = event.Start.AddDate(0, 0, 1)
+	default:
+		event.End = event.Start
+	}
+
+	if unsupported != nil {
+		*warnings = append(*warnings, fmt.Errorf("only the first occurrence of %q at %s is checked: %w", event.Summary, event.Start.Format(time.RFC3339), unsupported))
+	}
+
+	return event, true, nil
+}
+
+// parseTime parses a DATE or DATE-TIME value. The returned bool is true for
+// all day DATE values.
+func parseTime(p property, loc *time.Location) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.value)
+	if p.params["VALUE"] == "DATE" || len(value) == len(icsDateLayout) {
+		t, err := time.ParseInLocation(icsDateLayout, value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsDateTimeLayout, strings.TrimSuffix(value, "Z"))
+		return t, false, err
+	}
+	if tzid := p.params["TZID"]; tzid != "" {
+		// fall back to loc for timezone names go doesn't know, e.g. the
+		// windows names some calendar apps export
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation(icsDateTimeLayout, value, loc)
+
+	return t, false, err
+}
+
+// parseDuration parses the subset of ics durations used for events, e.g.
+// PT1H30M or P1D.
+func parseDuration(value string) (time.Duration, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	if v == value || v == "" {
+		return 0, fmt.Errorf("expected a duration starting with P")
+	}
+
+	var (
+		d      time.Duration
+		inTime bool
+		num    string
+	)
+	for _, r := range v {
+		switch {
+		case r == 'T':
+			inTime = true
+		case r >= '0' && r <= '9':
+			num += string(r)
+		default:
+			n, err := strconv.Atoi(num)
+			if err != nil {
+				return 0, fmt.Errorf("missing number before %c", r)
+			}
+			num = ""
+			switch {
+			case r == 'W' && !inTime:
+				d += time.Duration(n) * 7 * 24 * time.Hour
+			case r == 'D' && !inTime:
+				d += time.Duration(n) * 24 * time.Hour
+			case r == 'H' && inTime:
+				d += time.Duration(n) * time.Hour
+			case r == 'M' && inTime:
+				d += time.Duration(n) * time.Minute
+			case r == 'S' && inTime:
+				d += time.Duration(n) * time.Second
+			default:
+				return 0, fmt.Errorf("unexpected %c", r)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+var errUnsupportedRule = errors.New("unsupported recurrence rule")
+
+var icsWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+func parseRule(value string, loc *time.Location) (*Rule, error) {
+	rule := Rule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(v)
+		case "INTERVAL":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", v)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid COUNT %q", v)
+			}
+			rule.Count = n
+		case "UNTIL":
+			t, _, err := parseTime(property{value: v}, loc)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", v)
+			}
+			rule.Until = t
+		case "BYDAY":
+			for _, day := range strings.Split(v, ",") {
+				// ordinal prefixes like 1MO only make sense for monthly
+				// rules, which aren't supported
+				wd, ok := icsWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return nil, fmt.Errorf("%w: BYDAY %q", errUnsupportedRule, day)
+				}
+				rule.ByDay = append(rule.ByDay, wd)
+			}
+		}
+	}
+	if rule.Freq != "DAILY" && rule.Freq != "WEEKLY" {
+		return nil, fmt.Errorf("%w: FREQ %q, only DAILY and WEEKLY are supported", errUnsupportedRule, rule.Freq)
+	}
+
+	return &rule, nil
+}
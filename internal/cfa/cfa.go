@@ -1,6 +1,7 @@
 package cfa
 
 import (
+	"regexp"
 	"time"
 )
 
@@ -8,13 +9,28 @@ const (
 	loginEndpoint      = "https://crossfit-austin.triib.com/accounts/login/"
 	baseEndpoint       = "https://crossfit-austin.triib.com"
 	registerPath       = "register"
+	unregisterPath     = "unregister"
 	scheduleEndpoint   = "https://crossfit-austin.triib.com/schedule/json-feed/"
 	InHouseSessions    = "In House Sessions"
+	Timezone           = "America/Chicago"
 	classQueryName     = "name"
 	startDateQueryName = "start"
 	endDateQueryName   = "end"
 
 	MinimumRSVPTime = time.Hour * 120
+	// DefaultLeadTime is how long before the rsvp window opens a booking
+	// starts polling.
+	DefaultLeadTime = 5 * time.Minute
+	// MaxLeadTime is the longest a booking can start before the rsvp window
+	// opens and still have time to register before PollRSVP gives up.
+	MaxLeadTime = 9 * time.Minute
+
+	// defaultPollTimeout caps polling when the context has no deadline.
+	defaultPollTimeout = 10 * time.Minute
+	// minRegisterTime is how long polling needs after the rsvp window opens
+	// to get through the register attempts.
+	minRegisterTime = 30 * time.Second
+
 	registerRetries = 10
 
 	csrfTokenCookieName = "csrftoken"
@@ -34,6 +50,18 @@ const (
 	pollUnderOneSecond     = 250 * time.Millisecond
 )
 
+// waitlistPositionPattern finds the account's place on a class's wait list,
+// shown as e.g. "#3 on the wait list" or "wait list position: 3".
+var waitlistPositionPattern = regexp.MustCompile(`(?i)#\s*(\d+)\s+on the wait\s?list|wait\s?list position:?\s*#?(\d+)`)
+
+// Classes are the class names offered on the in house sessions calendar.
+// Requests are matched against schedule titles by substring, so a requested
+// class name must be contained in one of these.
+var Classes = []string{
+	"CrossFit Small Group Session",
+	"Range & Resilience",
+}
+
 type Cookie struct {
 	CSRFToken string
 	SessionID string
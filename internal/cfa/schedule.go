@@ -1,6 +1,7 @@
 package cfa
 
 import (
+	"strings"
 	"time"
 )
 
@@ -43,7 +44,20 @@ type Schedule struct {
 	URL     string     `json:"url"`
 }
 
+// Link returns the absolute address of the class page, empty when the class
+// has none. The schedule feed's URL is relative to the gym's site.
+func (s Schedule) Link() string {
+	if s.URL == "" || strings.HasPrefix(s.URL, "http://") || strings.HasPrefix(s.URL, "https://") {
+		return s.URL
+	}
+
+	return baseEndpoint + s.URL
+}
+
 type ScheduleRequest struct {
 	ClassName string     `json:"className"`
 	StartTime *time.Time `json:"startTime"`
+	// LeadTime overrides how long before the rsvp window opens booking
+	// starts. Zero uses the configured lead time.
+	LeadTime time.Duration `json:"leadTime,omitempty"`
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,20 +17,65 @@ import (
 type Service struct {
 	c      *http.Client
 	cookie *Cookie
+	trace  *Trace
+	// loc is the gym's timezone schedule times are returned in.
+	loc *time.Location
+	log io.Writer
+	// username and password are kept from Login to refresh the session.
+	username string
+	password string
 }
 
 func NewService(c *http.Client) (*Service, error) {
 	if c == nil {
 		return nil, fmt.Errorf("client cannot be nil")
 	}
-	return &Service{c: c}, nil
+	return &Service{c: c, log: os.Stdout}, nil
+}
+
+// SetLog sets where progress is logged, stdout by default.
+func (s *Service) SetLog(w io.Writer) {
+	s.log = w
 }
 
 func (s *Service) SetCookie(cookie Cookie) {
 	s.cookie = &cookie
 }
 
+// SetLocation sets the gym's timezone, which GetSchedule returns class times
+// in. Without it they keep the offset the schedule feed sent.
+func (s *Service) SetLocation(loc *time.Location) {
+	s.loc = loc
+}
+
+// SetTrace records the service's register attempts and logins in t.
+func (s *Service) SetTrace(t *Trace) {
+	s.trace = t
+}
+
 func (s *Service) Login(username, password string) (*Cookie, error) {
+	cookie, err := s.login(username, password)
+	s.trace.session(time.Now(), SessionLogin, err)
+	if err != nil {
+		return nil, err
+	}
+	s.username, s.password = username, password
+
+	return cookie, nil
+}
+
+// refresh logs in again with the credentials of the last login.
+func (s *Service) refresh() error {
+	if s.username == "" {
+		return fmt.Errorf("not logged in")
+	}
+	_, err := s.login(s.username, s.password)
+	s.trace.session(time.Now(), SessionRefresh, err)
+
+	return err
+}
+
+func (s *Service) login(username, password string) (*Cookie, error) {
 	values := make(url.Values)
 	values.Add("username", username)
 	values.Add("password", password)
@@ -60,29 +108,62 @@ func (s *Service) Login(username, password string) (*Cookie, error) {
 	return &cookie, nil
 }
 
+// PollRSVP waits for the class's rsvp window to open and registers. Polling
+// stops at the context's deadline, or after 10 minutes without one, and fails
+// right away with ErrTooEarly when the window opens too late to register by
+// then.
 func (s *Service) PollRSVP(ctx context.Context, sched Schedule) (RSVPStatus, error) {
-	// cap this polling at 20 minute to reduce costs/memory etc
-	const pollTimeout = 10 * time.Minute
-	timeout := time.NewTimer(pollTimeout)
-	var registerAttempts int
+	if sched.Start == nil {
+		return 0, fmt.Errorf("class %d has no start time", sched.ID)
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultPollTimeout)
+	}
+	budget := time.Until(deadline)
+	windowOpen := sched.Start.Add(-MinimumRSVPTime)
+	if last := deadline.Add(-minRegisterTime); windowOpen.After(last) {
+		return 0, fmt.Errorf("%w: rsvp window opens at %s, %s after polling has to stop to leave time to register",
+			ErrTooEarly, windowOpen.Format(time.RFC3339), windowOpen.Sub(last).Round(time.Second))
+	}
+	fmt.Fprintf(s.log, "polling for up to %s, rsvp window opens in %s\n", budget.Round(time.Second), time.Until(windowOpen).Round(time.Second))
+
+	timeout := time.NewTimer(budget)
+	defer timeout.Stop()
+	var (
+		registerAttempts int
+		refreshed        bool
+	)
 	for {
 		select {
 		case <-ctx.Done():
 			return 0, fmt.Errorf("polling cancelled: %w", ctx.Err())
 		case <-timeout.C:
-			return 0, fmt.Errorf("polling timed out after %s", pollTimeout)
+			return 0, fmt.Errorf("%w after %s", ErrPollTimeout, budget.Round(time.Second))
 		default:
 			// calculate poll time
 			until := time.Until(*sched.Start)
 			if until >= MinimumRSVPTime {
 				pollTime := calculatePollTime(until)
-				fmt.Printf("still not in rsvp window, sleeping for %s time, time until class: %s, remaining: %s\n", pollTime, until, until-MinimumRSVPTime)
-				time.Sleep(pollTime)
+				fmt.Fprintf(s.log, "still not in rsvp window, sleeping for %s time, time until class: %s, remaining: %s\n", pollTime, until, until-MinimumRSVPTime)
+				// wake up for the deadline instead of sleeping through it
+				sleep := time.NewTimer(pollTime)
+				select {
+				case <-ctx.Done():
+					sleep.Stop()
+					return 0, fmt.Errorf("polling cancelled: %w", ctx.Err())
+				case <-timeout.C:
+					sleep.Stop()
+					return 0, fmt.Errorf("%w after %s", ErrPollTimeout, budget.Round(time.Second))
+				case <-sleep.C:
+				}
 				continue
 			}
 
-			fmt.Println("polling done we are now in rsvp window, time to register")
+			fmt.Fprintln(s.log, "polling done we are now in rsvp window, time to register")
+			attemptAt := time.Now()
 			status, err := s.RSVP(sched)
+			s.trace.attempt(attemptAt, status, err)
 			if err != nil {
 				return 0, fmt.Errorf("unable to rsvp: %w", err)
 			}
@@ -92,16 +173,25 @@ func (s *Service) PollRSVP(ctx context.Context, sched Schedule) (RSVPStatus, err
 			case UNREGISTERED, UNREGISTERED_WAITLIST:
 				registerAttempts++
 				if registerAttempts >= registerRetries {
-					return 0, fmt.Errorf("unable to register after %d attempts", registerAttempts)
+					return 0, fmt.Errorf("%w after %d attempts", ErrNotRegistered, registerAttempts)
 				}
-				fmt.Printf("failed to register, retrying shortly, attempts: %d\n", registerAttempts)
+				fmt.Fprintf(s.log, "failed to register, retrying shortly, attempts: %d\n", registerAttempts)
 				continue
 			default:
 				registerAttempts++
 				if registerAttempts >= registerRetries {
-					return 0, fmt.Errorf("unable to register after %d attempts", registerAttempts)
+					return 0, fmt.Errorf("%w after %d attempts", ErrNotRegistered, registerAttempts)
+				}
+				// the class page didn't show any rsvp state, which is what it
+				// looks like logged out, so log in again before retrying
+				if !refreshed {
+					refreshed = true
+					if err := s.refresh(); err != nil {
+						return 0, fmt.Errorf("unable to refresh session: %w", err)
+					}
+					fmt.Fprintln(s.log, "refreshed session")
 				}
-				fmt.Printf("failed to register, retrying shortly, attempts: %d\n", registerAttempts)
+				fmt.Fprintf(s.log, "failed to register, retrying shortly, attempts: %d\n", registerAttempts)
 				continue
 			}
 		}
@@ -110,7 +200,7 @@ func (s *Service) PollRSVP(ctx context.Context, sched Schedule) (RSVPStatus, err
 
 func (s *Service) RSVP(schedule Schedule) (RSVPStatus, error) {
 	endpoint := baseEndpoint + schedule.URL + registerPath + "/"
-	fmt.Printf("submitting rsvp request to: %s\n", endpoint)
+	fmt.Fprintf(s.log, "submitting rsvp request to: %s\n", endpoint)
 	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
 	if err != nil {
 		return 0, fmt.Errorf("unable to generate new request: %w", err)
@@ -125,7 +215,7 @@ func (s *Service) RSVP(schedule Schedule) (RSVPStatus, error) {
 	if resp.StatusCode != http.StatusFound {
 		return 0, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
 	}
-	fmt.Println("submitted rsvp request successfully, checking rsvp..")
+	fmt.Fprintln(s.log, "submitted rsvp request successfully, checking rsvp..")
 
 	// make sure we rsvped for the class
 	status, err := s.CheckRSVP(schedule)
@@ -133,13 +223,15 @@ func (s *Service) RSVP(schedule Schedule) (RSVPStatus, error) {
 		return 0, fmt.Errorf("unable to check rsvp: %w", err)
 	}
 
-	fmt.Printf("RSVP status code: %d: %s\n", status, status.String())
+	fmt.Fprintf(s.log, "RSVP status code: %d: %s\n", status, status.String())
 
 	return status, nil
 }
 
-func (s *Service) CheckRSVP(sched Schedule) (RSVPStatus, error) {
-	endpoint := baseEndpoint + sched.URL
+// Unregister cancels the account's RSVP or wait list spot for the class.
+func (s *Service) Unregister(schedule Schedule) (RSVPStatus, error) {
+	endpoint := baseEndpoint + schedule.URL + unregisterPath + "/"
+	fmt.Fprintf(s.log, "submitting unregister request to: %s\n", endpoint)
 	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
 	if err != nil {
 		return 0, fmt.Errorf("unable to generate new request: %w", err)
@@ -151,19 +243,31 @@ func (s *Service) CheckRSVP(sched Schedule) (RSVPStatus, error) {
 	if err != nil {
 		return 0, fmt.Errorf("unable to complete request: %w", err)
 	}
-	if resp.StatusCode != http.StatusOK {
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
 		return 0, fmt.Errorf("unexpected response code: %d", resp.StatusCode)
 	}
 
-	// make sure we rsvped for the class
-	body, err := ioutil.ReadAll(resp.Body)
+	// make sure we're no longer registered for the class
+	status, err := s.CheckRSVP(schedule)
 	if err != nil {
-		return 0, fmt.Errorf("unable to read response body: %w", err)
+		return 0, fmt.Errorf("unable to check rsvp: %w", err)
 	}
-	bodyStr := string(body)
-	fmt.Printf("Class page body: %s\n", string(body))
-	resp.Body.Close()
+	if status == RSVPED || status == WAITLISTED {
+		return status, fmt.Errorf("still %s after unregistering", status)
+	}
+
+	return status, nil
+}
+
+func (s *Service) CheckRSVP(sched Schedule) (RSVPStatus, error) {
+	bodyStr, err := s.classPage(sched)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(s.log, "Class page body: %s\n", bodyStr)
 
+	// make sure we rsvped for the class
 	if strings.Contains(bodyStr, unregisteredMessage) {
 		return UNREGISTERED, nil
 	} else if strings.Contains(bodyStr, unregisteredWaitlistMessage) {
@@ -177,6 +281,58 @@ func (s *Service) CheckRSVP(sched Schedule) (RSVPStatus, error) {
 	return -1, nil
 }
 
+// WaitlistPosition returns the account's position on the class's wait list.
+// It's 0 when the account isn't on the wait list or the class page doesn't
+// show a position.
+func (s *Service) WaitlistPosition(sched Schedule) (int, error) {
+	bodyStr, err := s.classPage(sched)
+	if err != nil {
+		return 0, err
+	}
+	if !strings.Contains(bodyStr, waitlistMessage) {
+		return 0, nil
+	}
+
+	m := waitlistPositionPattern.FindStringSubmatch(bodyStr)
+	if m == nil {
+		return 0, nil
+	}
+	for _, group := range m[1:] {
+		if n, err := strconv.Atoi(group); err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// classPage returns the class's page as seen by the logged in account.
+func (s *Service) classPage(sched Schedule) (string, error) {
+	endpoint := baseEndpoint + sched.URL
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate new request: %w", err)
+	}
+	req.Header.Add("Cookie", csrfTokenCookieName+"="+s.cookie.CSRFToken)
+	req.Header.Add("Cookie", sessionIDCookieName+"="+s.cookie.SessionID)
+
+	resp, err := s.c.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to complete request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected response code: %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read response body: %w", err)
+	}
+
+	return string(body), nil
+}
+
 func (s *Service) GetSchedule(params ScheduleParams) ([]Schedule, error) {
 	values := make(url.Values)
 	values.Add(classQueryName, params.Name)
@@ -205,9 +361,14 @@ func (s *Service) GetSchedule(params ScheduleParams) ([]Schedule, error) {
 	}
 	resp.Body.Close()
 
-	// clear out seconds from start time
+	// clear out seconds from start time, in the gym's timezone rather than
+	// the local one, which is UTC in lambda
 	for i := range schedules {
-		schedules[i].Start = timePtr(time.Date(schedules[i].Start.Year(), schedules[i].Start.Month(), schedules[i].Start.Day(), schedules[i].Start.Hour(), schedules[i].Start.Minute(), 0, 0, time.Local))
+		start := *schedules[i].Start
+		if s.loc != nil {
+			start = start.In(s.loc)
+		}
+		schedules[i].Start = timePtr(time.Date(start.Year(), start.Month(), start.Day(), start.Hour(), start.Minute(), 0, 0, start.Location()))
 	}
 
 	return schedules, nil
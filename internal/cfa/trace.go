@@ -0,0 +1,69 @@
+package cfa
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPollTimeout is returned when polling gives up before registering.
+	ErrPollTimeout = errors.New("polling timed out")
+	// ErrNotRegistered is returned when every register attempt left the
+	// account unregistered.
+	ErrNotRegistered = errors.New("unable to register")
+	// ErrTooEarly is returned when the rsvp window opens too late for
+	// polling to register before the context's deadline.
+	ErrTooEarly = errors.New("trigger too early")
+)
+
+// Session events.
+const (
+	SessionLogin   = "login"
+	SessionRefresh = "refresh"
+)
+
+// Attempt is one register request.
+type Attempt struct {
+	Time   time.Time `json:"time"`
+	Status string    `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// SessionEvent is a login, either the first one or a refresh after the
+// session stopped working.
+type SessionEvent struct {
+	Time  time.Time `json:"time"`
+	Kind  string    `json:"kind"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Trace records the requests a booking made. Set one on a service with
+// SetTrace before logging in.
+type Trace struct {
+	Attempts []Attempt      `json:"attempts"`
+	Sessions []SessionEvent `json:"sessions"`
+}
+
+func (t *Trace) attempt(at time.Time, status RSVPStatus, err error) {
+	if t == nil {
+		return
+	}
+	a := Attempt{Time: at}
+	if err != nil {
+		a.Error = err.Error()
+	} else {
+		a.Status = status.String()
+	}
+	t.Attempts = append(t.Attempts, a)
+}
+
+func (t *Trace) session(at time.Time, kind string, err error) {
+	if t == nil {
+		return
+	}
+	e := SessionEvent{Time: at, Kind: kind}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	t.Sessions = append(t.Sessions, e)
+}
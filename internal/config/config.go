@@ -0,0 +1,564 @@
+// Package config holds the deployment settings shared by the scheduler
+// command, the rsvp lambda and the rsvperd daemon.
+//
+// Settings are read from an optional yaml or json file and can be overridden
+// with environment variables:
+//
+//	RSVPER_CONFIG            path of the config file
+//	RSVPER_AWS_REGION        awsRegion
+//	RSVPER_TIMEZONE          timezone
+//	RSVPER_LAMBDA_ARN        lambdaArn
+//	RSVPER_ROLE_ARN          roleArn
+//	RSVPER_SCHEDULE_GROUP    scheduleGroup
+//	RSVPER_TAGS              tags, as comma separated key=value pairs
+//	RSVPER_SECRET_STORE      secretStore
+//	RSVPER_SECRETS_FILE      secretsFile
+//	RSVPER_CREDENTIALS       credentials
+//	RSVPER_LEAD_TIME         leadTime, as a duration like 5m
+//	RSVPER_RETRY_ATTEMPTS    retry.maximumAttempts
+//	RSVPER_DEAD_LETTER_ARN   deadLetterArn
+//	RSVPER_REQUESTS          requests
+//	RSVPER_BLACKOUTS         blackouts
+//	RSVPER_PLAN_HORIZON      planHorizon, as a duration like 48h
+//	RSVPER_DIGEST            digest
+//	RSVPER_IDEMPOTENCY_TABLE idempotencyTable
+//	RSVPER_TWILIO_FROM       notify.twilio.from
+//	RSVPER_TWILIO_TO         notify.twilio.to, as comma separated numbers
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+const (
+	// DefaultPath is used when neither a path nor RSVPER_CONFIG is set.
+	DefaultPath = "rsvper.yaml"
+
+	defaultRegion        = "us-east-2"
+	defaultScheduleGroup = "default"
+	defaultSecretsFile   = "secrets.json"
+
+	envConfig        = "RSVPER_CONFIG"
+	envRegion        = "RSVPER_AWS_REGION"
+	envTimezone      = "RSVPER_TIMEZONE"
+	envLambdaARN     = "RSVPER_LAMBDA_ARN"
+	envRoleARN       = "RSVPER_ROLE_ARN"
+	envScheduleGroup = "RSVPER_SCHEDULE_GROUP"
+	envTags          = "RSVPER_TAGS"
+	envSecretStore   = "RSVPER_SECRET_STORE"
+	envSecretsFile   = "RSVPER_SECRETS_FILE"
+	envCredentials   = "RSVPER_CREDENTIALS"
+	envLeadTime      = "RSVPER_LEAD_TIME"
+	envRetryAttempts = "RSVPER_RETRY_ATTEMPTS"
+	envDeadLetterARN = "RSVPER_DEAD_LETTER_ARN"
+	envRequests      = "RSVPER_REQUESTS"
+	envBlackouts     = "RSVPER_BLACKOUTS"
+	envPlanHorizon   = "RSVPER_PLAN_HORIZON"
+	envDigest        = "RSVPER_DIGEST"
+	envIdempotency   = "RSVPER_IDEMPOTENCY_TABLE"
+	envTwilioFrom    = "RSVPER_TWILIO_FROM"
+	envTwilioTo      = "RSVPER_TWILIO_TO"
+
+	defaultPlanHorizon = 48 * time.Hour
+
+	// EventBridge Scheduler's limits for retry policies.
+	maxRetryAttempts = 185
+	minEventAge      = time.Minute
+	maxEventAge      = 24 * time.Hour
+)
+
+// Secret stores credentials can be resolved from.
+const (
+	SecretStoreAWS  = "aws"
+	SecretStoreFile = "file"
+)
+
+type Config struct {
+	// AWSRegion is the region the scheduler and lambda run in.
+	AWSRegion string `yaml:"awsRegion"`
+	// Timezone is the gym's timezone. Class times, shorthand requests and
+	// trigger schedules are all interpreted in it.
+	Timezone string `yaml:"timezone"`
+	// LambdaARN is the rsvp lambda EventBridge triggers invoke.
+	LambdaARN string `yaml:"lambdaArn"`
+	// RoleARN is the role EventBridge Scheduler assumes to invoke the lambda.
+	RoleARN string `yaml:"roleArn"`
+	// ScheduleGroup is the EventBridge schedule group triggers are created
+	// in. It is created with Tags if it doesn't exist.
+	ScheduleGroup string `yaml:"scheduleGroup"`
+	// Tags are applied to the resources rsvper creates.
+	Tags map[string]string `yaml:"tags"`
+	// SecretStore is where credentials are kept: aws for Secrets Manager or
+	// file for SecretsFile.
+	SecretStore string `yaml:"secretStore"`
+	// SecretsFile is the local secrets file used by the file secret store.
+	SecretsFile string `yaml:"secretsFile"`
+	// Credentials references the account's credentials in the secret store.
+	// It is put in every trigger and resolved when the trigger runs.
+	Credentials string `yaml:"credentials"`
+	// LeadTime is how long before the rsvp window opens booking starts.
+	LeadTime time.Duration `yaml:"leadTime"`
+	// ClassLeadTimes overrides LeadTime for classes whose title contains the
+	// key, e.g. a longer lead time for popular classes.
+	ClassLeadTimes map[string]time.Duration `yaml:"classLeadTimes"`
+	// Retry is how EventBridge retries failed lambda invocations.
+	Retry Retry `yaml:"retry"`
+	// DeadLetterARN is the SQS queue failed invocations are sent to, and
+	// that the replay command reads from.
+	DeadLetterARN string `yaml:"deadLetterArn"`
+	// Requests is the requests file the planning job reads, a local path or
+	// an s3://bucket/key url.
+	Requests string `yaml:"requests"`
+	// Blackouts is the blackouts file the planning job reads, a local path
+	// or an s3://bucket/key url. Empty means no blackouts.
+	Blackouts string `yaml:"blackouts"`
+	// PlanHorizon limits the planning job to classes whose rsvp window opens
+	// within this long.
+	PlanHorizon time.Duration `yaml:"planHorizon"`
+	// IdempotencyTable is the DynamoDB table that remembers which booking
+	// notifications were sent, so duplicate lambda invocations don't send
+	// them twice. Without it duplicates are only caught within a process.
+	IdempotencyTable string `yaml:"idempotencyTable"`
+	// Digest is how often the booking digest goes out, daily or weekly. It
+	// covers the classes starting and the failures since then.
+	Digest string `yaml:"digest"`
+	// Notify is where booking outcomes are sent.
+	Notify Notify `yaml:"notify"`
+}
+
+// Digest periods.
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Notify configures the notifiers. Each one is only used when it's set.
+type Notify struct {
+	// Preferences apply to every notifier.
+	Preferences Preferences `yaml:"preferences"`
+	Twilio      *Twilio     `yaml:"twilio"`
+	Email       *Email      `yaml:"email"`
+	// Webhooks are posted every event.
+	Webhooks []Webhook `yaml:"webhooks"`
+}
+
+// What Preferences.Only can be set to.
+const (
+	OnlyFailures  = "failures"
+	OnlySuccesses = "successes"
+)
+
+type Preferences struct {
+	// Only is failures or successes to only send those events. Successes are
+	// booked, waitlisted and promoted.
+	Only string `yaml:"only"`
+	// QuietHours stops every notification but failures between two times of
+	// day.
+	QuietHours *QuietHours `yaml:"quietHours"`
+}
+
+// QuietHours are times of day in Timezone, like 22:00 and 07:00. The range
+// can wrap past midnight.
+type QuietHours struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// Parse returns the start and end as offsets from midnight.
+func (q QuietHours) Parse() (time.Duration, time.Duration, error) {
+	start, err := parseClock(q.Start)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours start: %w", err)
+	}
+	end, err := parseClock(q.End)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid quiet hours end: %w", err)
+	}
+
+	return start, end, nil
+}
+
+// Channel holds the settings every notifier has.
+type Channel struct {
+	// MinSeverity drops events below info, warning or error. Failures are
+	// errors, wait lists and cancellations warnings, everything else info.
+	MinSeverity string `yaml:"minSeverity"`
+	// Templates override the message by event kind, or default for every
+	// kind. They are Go text/templates.
+	Templates map[string]string `yaml:"templates"`
+}
+
+type Twilio struct {
+	// From is the Twilio number texts are sent from.
+	From string `yaml:"from"`
+	// To are the numbers texts are sent to.
+	To      []string `yaml:"to"`
+	Channel `yaml:",inline"`
+}
+
+// Email is an SMTP server to send emails through. The password is read from
+// RSVPER_SMTP_PASSWORD so it stays out of the config file.
+type Email struct {
+	Host string `yaml:"host"`
+	// Port defaults to 587, the submission port.
+	Port int `yaml:"port"`
+	// Username turns on PLAIN auth when set.
+	Username string   `yaml:"username"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	// Insecure sends without STARTTLS. Only use it for a local test server.
+	Insecure bool `yaml:"insecure"`
+	// MinSeverity drops events below info, warning or error.
+	MinSeverity string `yaml:"minSeverity"`
+	// Templates override the email by event kind, or default for every kind.
+	Templates map[string]EmailTemplate `yaml:"templates"`
+}
+
+// EmailTemplate overrides parts of an email. Empty parts use the default.
+type EmailTemplate struct {
+	Subject string `yaml:"subject"`
+	Text    string `yaml:"text"`
+	// HTML is a Go html/template.
+	HTML string `yaml:"html"`
+}
+
+// Webhook formats.
+const (
+	WebhookJSON    = "json"
+	WebhookSlack   = "slack"
+	WebhookDiscord = "discord"
+)
+
+type Webhook struct {
+	URL string `yaml:"url"`
+	// Format is json, the default, slack or discord.
+	Format string `yaml:"format"`
+	// SecretEnv names the environment variable holding the secret payloads
+	// are signed with. Payloads aren't signed without one.
+	SecretEnv string `yaml:"secretEnv"`
+	// Attempts is how many times a delivery is tried, 3 by default.
+	Attempts int `yaml:"attempts"`
+	// Templates are only used by the slack and discord formats, for the
+	// message text.
+	Channel `yaml:",inline"`
+}
+
+type Retry struct {
+	// MaximumAttempts is how many times an invocation is retried.
+	MaximumAttempts int64 `yaml:"maximumAttempts"`
+	// MaximumEventAge stops retries once the invocation is this old. Zero
+	// uses the EventBridge default of a day.
+	MaximumEventAge time.Duration `yaml:"maximumEventAge"`
+}
+
+func Default() Config {
+	return Config{
+		AWSRegion:     defaultRegion,
+		Timezone:      cfa.Timezone,
+		ScheduleGroup: defaultScheduleGroup,
+		SecretStore:   SecretStoreAWS,
+		SecretsFile:   defaultSecretsFile,
+		LeadTime:      cfa.DefaultLeadTime,
+		PlanHorizon:   defaultPlanHorizon,
+		Digest:        DigestDaily,
+	}
+}
+
+// Load reads the config file at path, falling back to RSVPER_CONFIG and then
+// DefaultPath when path is empty, and applies environment overrides. A missing
+// file is only an error when the path was given explicitly.
+func Load(path string) (*Config, error) {
+	explicit := path != ""
+	if path == "" {
+		path = os.Getenv(envConfig)
+		explicit = path != ""
+	}
+	if path == "" {
+		path = DefaultPath
+	}
+
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist) && !explicit:
+	case err != nil:
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	default:
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("unable to decode config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	overrides := map[string]*string{
+		envRegion:        &c.AWSRegion,
+		envTimezone:      &c.Timezone,
+		envLambdaARN:     &c.LambdaARN,
+		envRoleARN:       &c.RoleARN,
+		envScheduleGroup: &c.ScheduleGroup,
+		envSecretStore:   &c.SecretStore,
+		envSecretsFile:   &c.SecretsFile,
+		envCredentials:   &c.Credentials,
+		envDeadLetterARN: &c.DeadLetterARN,
+		envRequests:      &c.Requests,
+		envBlackouts:     &c.Blackouts,
+		envDigest:        &c.Digest,
+		envIdempotency:   &c.IdempotencyTable,
+	}
+	for env, field := range overrides {
+		if v, ok := os.LookupEnv(env); ok {
+			*field = v
+		}
+	}
+
+	if v, ok := os.LookupEnv(envLeadTime); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", envLeadTime, v, err)
+		}
+		c.LeadTime = d
+	}
+
+	if v, ok := os.LookupEnv(envPlanHorizon); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", envPlanHorizon, v, err)
+		}
+		c.PlanHorizon = d
+	}
+	if v, ok := os.LookupEnv(envRetryAttempts); ok {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s %q: %w", envRetryAttempts, v, err)
+		}
+		c.Retry.MaximumAttempts = n
+	}
+
+	from, fromOK := os.LookupEnv(envTwilioFrom)
+	to, toOK := os.LookupEnv(envTwilioTo)
+	if fromOK || toOK {
+		if c.Notify.Twilio == nil {
+			c.Notify.Twilio = &Twilio{}
+		}
+		if fromOK {
+			c.Notify.Twilio.From = from
+		}
+		if toOK {
+			c.Notify.Twilio.To = splitList(to)
+		}
+	}
+
+	v, ok := os.LookupEnv(envTags)
+	if !ok {
+		return nil
+	}
+	c.Tags = make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return fmt.Errorf("invalid %s entry %q, expected key=value", envTags, pair)
+		}
+		c.Tags[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.AWSRegion == "" {
+		return fmt.Errorf("awsRegion cannot be empty")
+	}
+	if _, err := time.LoadLocation(c.Timezone); err != nil {
+		return fmt.Errorf("invalid timezone %q: %w", c.Timezone, err)
+	}
+	if c.ScheduleGroup == "" {
+		return fmt.Errorf("scheduleGroup cannot be empty")
+	}
+	if err := validLeadTime(c.LeadTime); err != nil {
+		return fmt.Errorf("invalid leadTime: %w", err)
+	}
+	for class, d := range c.ClassLeadTimes {
+		if err := validLeadTime(d); err != nil {
+			return fmt.Errorf("invalid classLeadTimes entry %q: %w", class, err)
+		}
+	}
+	if t := c.Notify.Twilio; t != nil && (t.From == "" || len(t.To) == 0) {
+		return fmt.Errorf("notify.twilio needs both from and to")
+	}
+	if e := c.Notify.Email; e != nil && (e.Host == "" || e.From == "" || len(e.To) == 0) {
+		return fmt.Errorf("notify.email needs host, from and to")
+	}
+	if err := c.Notify.validate(); err != nil {
+		return err
+	}
+	for i, w := range c.Notify.Webhooks {
+		if w.URL == "" {
+			return fmt.Errorf("notify.webhooks[%d] needs a url", i)
+		}
+		switch w.Format {
+		case "", WebhookJSON, WebhookSlack, WebhookDiscord:
+		default:
+			return fmt.Errorf("invalid notify.webhooks[%d].format %q, expected %s, %s or %s", i, w.Format, WebhookJSON, WebhookSlack, WebhookDiscord)
+		}
+		if w.Attempts < 0 {
+			return fmt.Errorf("invalid notify.webhooks[%d].attempts %d, must be at least 0", i, w.Attempts)
+		}
+	}
+	if c.PlanHorizon <= 0 {
+		return fmt.Errorf("invalid planHorizon %s, must be more than 0", c.PlanHorizon)
+	}
+	if c.Retry.MaximumAttempts < 0 || c.Retry.MaximumAttempts > maxRetryAttempts {
+		return fmt.Errorf("invalid retry.maximumAttempts %d, expected 0 to %d", c.Retry.MaximumAttempts, maxRetryAttempts)
+	}
+	if age := c.Retry.MaximumEventAge; age != 0 && (age < minEventAge || age > maxEventAge) {
+		return fmt.Errorf("invalid retry.maximumEventAge %s, expected %s to %s", age, minEventAge, maxEventAge)
+	}
+	if c.Digest != DigestDaily && c.Digest != DigestWeekly {
+		return fmt.Errorf("invalid digest %q, expected %s or %s", c.Digest, DigestDaily, DigestWeekly)
+	}
+	if c.SecretStore != SecretStoreAWS && c.SecretStore != SecretStoreFile {
+		return fmt.Errorf("invalid secretStore %q, expected %s or %s", c.SecretStore, SecretStoreAWS, SecretStoreFile)
+	}
+
+	return nil
+}
+
+func (n *Notify) validate() error {
+	switch n.Preferences.Only {
+	case "", OnlyFailures, OnlySuccesses:
+	default:
+		return fmt.Errorf("invalid notify.preferences.only %q, expected %s or %s", n.Preferences.Only, OnlyFailures, OnlySuccesses)
+	}
+	if q := n.Preferences.QuietHours; q != nil {
+		if _, _, err := q.Parse(); err != nil {
+			return fmt.Errorf("invalid notify.preferences.quietHours: %w", err)
+		}
+	}
+
+	severities := map[string]string{}
+	if n.Twilio != nil {
+		severities["notify.twilio"] = n.Twilio.MinSeverity
+	}
+	if n.Email != nil {
+		severities["notify.email"] = n.Email.MinSeverity
+	}
+	for i, w := range n.Webhooks {
+		severities[fmt.Sprintf("notify.webhooks[%d]", i)] = w.MinSeverity
+	}
+	for name, severity := range severities {
+		switch severity {
+		case "", "info", "warning", "error":
+		default:
+			return fmt.Errorf("invalid %s.minSeverity %q, expected info, warning or error", name, severity)
+		}
+	}
+
+	return nil
+}
+
+func parseClock(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time like 22:00", v)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+
+	return out
+}
+
+func validLeadTime(d time.Duration) error {
+	if d <= 0 || d > cfa.MaxLeadTime {
+		return fmt.Errorf("%s must be more than 0 and at most %s", d, cfa.MaxLeadTime)
+	}
+
+	return nil
+}
+
+// Location returns the gym's timezone.
+func (c *Config) Location() *time.Location {
+	// validated when the config was loaded
+	loc, _ := time.LoadLocation(c.Timezone)
+
+	return loc
+}
+
+// DigestPeriod returns how long the digest covers.
+func (c *Config) DigestPeriod() time.Duration {
+	if c.Digest == DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+
+	return 24 * time.Hour
+}
+
+// RequireTargets checks the settings needed to create EventBridge triggers.
+func (c *Config) RequireTargets() error {
+	var missing []string
+	if c.LambdaARN == "" {
+		missing = append(missing, "lambdaArn ("+envLambdaARN+")")
+	}
+	if c.RoleARN == "" {
+		missing = append(missing, "roleArn ("+envRoleARN+")")
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing config: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// RequireRequests checks that the planning job knows where the requests are.
+func (c *Config) RequireRequests() error {
+	if c.Requests == "" {
+		return fmt.Errorf("missing config: requests (%s)", envRequests)
+	}
+
+	return nil
+}
+
+// RequireCredentials checks that a credentials reference is set for triggers.
+func (c *Config) RequireCredentials() error {
+	if c.Credentials == "" {
+		return fmt.Errorf("missing config: credentials (%s)", envCredentials)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package deadletter reads task requests back from the dead letter queue
+// failed trigger invocations and the lambda's on failure destination are
+// sent to, and from saved payload files, so they can be replayed.
+package deadletter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+// Message is a failed invocation read from the dead letter queue.
+type Message struct {
+	ID   string
+	Task scheduler.TaskRequest
+	// Error is why the invocation failed, when the queue recorded it.
+	Error string
+	// Sent is when the message was sent to the queue.
+	Sent time.Time
+
+	receiptHandle string
+}
+
+// destination is the record lambda sends to an on failure destination once
+// an async invocation runs out of retries. The task request is wrapped in
+// it, along with the lambda's error.
+type destination struct {
+	RequestContext struct {
+		Condition              string `json:"condition"`
+		ApproximateInvokeCount int    `json:"approximateInvokeCount"`
+	} `json:"requestContext"`
+	RequestPayload  *scheduler.TaskRequest `json:"requestPayload"`
+	ResponsePayload json.RawMessage        `json:"responsePayload"`
+}
+
+// DecodeTask decodes a failed invocation's payload. Both the bare task
+// request EventBridge and lambda dead letter queues carry, and the wrapped
+// form lambda failure destinations send, are accepted.
+func DecodeTask(data []byte) (scheduler.TaskRequest, error) {
+	task, _, err := decode(data)
+	return task, err
+}
+
+// decode decodes a failed invocation's payload along with the error recorded
+// in it. Only lambda failure destinations record one, the dead letter
+// queues put it in message attributes instead.
+func decode(data []byte) (scheduler.TaskRequest, string, error) {
+	var wrapped destination
+	if err := json.Unmarshal(data, &wrapped); err != nil {
+		return scheduler.TaskRequest{}, "", fmt.Errorf("unable to decode task request: %w", err)
+	}
+	if wrapped.RequestPayload != nil {
+		return *wrapped.RequestPayload, wrapped.errorText(), nil
+	}
+
+	var task scheduler.TaskRequest
+	if err := json.Unmarshal(data, &task); err != nil {
+		return scheduler.TaskRequest{}, "", fmt.Errorf("unable to decode task request: %w", err)
+	}
+	if task.Schedule.ID == 0 {
+		return scheduler.TaskRequest{}, "", fmt.Errorf("payload is not a task request")
+	}
+
+	return task, "", nil
+}
+
+// errorText describes why the invocation failed, e.g. "RetriesExhausted
+// after 3 invocations: timeout: unable to poll rsvp: ...".
+func (d destination) errorText() string {
+	var response struct {
+		ErrorMessage string `json:"errorMessage"`
+	}
+	// the response is the function's error object, anything else has no
+	// message to show
+	json.Unmarshal(d.ResponsePayload, &response)
+
+	text := d.RequestContext.Condition
+	if n := d.RequestContext.ApproximateInvokeCount; n > 0 {
+		text = fmt.Sprintf("%s after %d invocations", text, n)
+	}
+	if response.ErrorMessage != "" {
+		text += ": " + response.ErrorMessage
+	}
+
+	return text
+}
+
+// LoadFile reads a task request saved to a file.
+func LoadFile(path string) (scheduler.TaskRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return scheduler.TaskRequest{}, fmt.Errorf("unable to read payload file: %w", err)
+	}
+
+	return DecodeTask(data)
+}
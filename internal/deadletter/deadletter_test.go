@@ -0,0 +1,71 @@
+package deadletter
+
+import (
+	"testing"
+)
+
+const task = `{"schedule": {"id": 42, "title": "CrossFit Small Group Session", "start": "2026-10-20T17:30:00-05:00"}, "credentials": "rsvper/me", "account": "me@example.com"}`
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		error string
+	}{
+		{
+			name: "scheduler dead letter",
+			body: task,
+		},
+		{
+			name: "lambda destination",
+			body: `{
+				"version": "1.0",
+				"timestamp": "2026-10-15T22:25:13.568Z",
+				"requestContext": {
+					"requestId": "e4b46cbf-b738-4f7a-8880-a18cdf61200e",
+					"functionArn": "arn:aws:lambda:us-east-2:123456789012:function:RSVPer:$LATEST",
+					"condition": "RetriesExhausted",
+					"approximateInvokeCount": 3
+				},
+				"requestPayload": ` + task + `,
+				"responseContext": {"statusCode": 200, "executedVersion": "$LATEST", "functionError": "Unhandled"},
+				"responsePayload": {"errorMessage": "timeout: unable to poll rsvp: polling timed out after 9m30s", "errorType": "wrapError"}
+			}`,
+			error: "RetriesExhausted after 3 invocations: timeout: unable to poll rsvp: polling timed out after 9m30s",
+		},
+		{
+			name: "lambda destination without an error object",
+			body: `{
+				"requestContext": {"condition": "EventAgeExceeded", "approximateInvokeCount": 1},
+				"requestPayload": ` + task + `,
+				"responsePayload": null
+			}`,
+			error: "EventAgeExceeded after 1 invocations",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, text, err := decode([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("unable to decode: %v", err)
+			}
+			if got.Schedule.ID != 42 || got.Credentials != "rsvper/me" || got.Account != "me@example.com" {
+				t.Errorf("got task %+v, want class 42 for me@example.com", got)
+			}
+			if got.Schedule.Start == nil {
+				t.Error("got no class start")
+			}
+			if text != tt.error {
+				t.Errorf("got error %q, want %q", text, tt.error)
+			}
+		})
+	}
+}
+
+func TestDecodeTaskRejectsOtherPayloads(t *testing.T) {
+	for _, body := range []string{`{"job": "digest"}`, `[]`, `not json`} {
+		if _, err := DecodeTask([]byte(body)); err == nil {
+			t.Errorf("got no error decoding %s", body)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package deadletter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// maxBatch is the most messages SQS returns from a single receive.
+const maxBatch = 10
+
+// errorAttributes are the message attributes EventBridge Scheduler and lambda
+// use to record why an invocation failed.
+var errorAttributes = []string{"ERROR_MESSAGE", "ErrorMessage", "ERROR_CODE", "ErrorCode"}
+
+// Queue is an SQS dead letter queue.
+type Queue struct {
+	client *sqs.SQS
+	url    string
+}
+
+func NewQueue(sess *session.Session, queueARN string) (*Queue, error) {
+	if sess == nil {
+		return nil, fmt.Errorf("session cannot be nil")
+	}
+	parsed, err := arn.Parse(queueARN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dead letter queue arn %q: %w", queueARN, err)
+	}
+	if parsed.Service != sqs.ServiceName {
+		return nil, fmt.Errorf("dead letter queue %s is not an sqs queue", queueARN)
+	}
+
+	client := sqs.New(sess, aws.NewConfig().WithRegion(parsed.Region))
+	out, err := client.GetQueueUrl(&sqs.GetQueueUrlInput{
+		QueueName:              aws.String(parsed.Resource),
+		QueueOwnerAWSAccountId: aws.String(parsed.AccountID),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get queue url: %w", err)
+	}
+
+	return &Queue{client: client, url: aws.StringValue(out.QueueUrl)}, nil
+}
+
+// Receive reads up to max messages from the queue. Received messages are
+// hidden from other readers until they are deleted or their visibility
+// timeout runs out.
+func (q *Queue) Receive(max int) ([]Message, error) {
+	var messages []Message
+	for len(messages) < max {
+		batch := max - len(messages)
+		if batch > maxBatch {
+			batch = maxBatch
+		}
+		received, err := q.receive(batch, nil)
+		messages = append(messages, received...)
+		if err != nil {
+			return messages, err
+		}
+		if len(received) == 0 {
+			break
+		}
+	}
+
+	return messages, nil
+}
+
+// Peek reads up to a batch of messages without hiding them from other
+// readers, so they stay in the queue for replay.
+func (q *Queue) Peek() ([]Message, error) {
+	return q.receive(maxBatch, aws.Int64(0))
+}
+
+func (q *Queue) receive(batch int, visibility *int64) ([]Message, error) {
+	out, err := q.client.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:              aws.String(q.url),
+		MaxNumberOfMessages:   aws.Int64(int64(batch)),
+		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+		AttributeNames:        aws.StringSlice([]string{sqs.MessageSystemAttributeNameSentTimestamp}),
+		VisibilityTimeout:     visibility,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to receive messages: %w", err)
+	}
+
+	messages := make([]Message, 0, len(out.Messages))
+	for _, m := range out.Messages {
+		msg := Message{
+			ID:            aws.StringValue(m.MessageId),
+			receiptHandle: aws.StringValue(m.ReceiptHandle),
+		}
+		task, text, err := decode([]byte(aws.StringValue(m.Body)))
+		if err != nil {
+			return messages, fmt.Errorf("unable to decode message %s: %w", msg.ID, err)
+		}
+		msg.Task = task
+		msg.Error = text
+		if msg.Error == "" {
+			msg.Error = errorText(m.MessageAttributes)
+		}
+		if ms, err := strconv.ParseInt(aws.StringValue(m.Attributes[sqs.MessageSystemAttributeNameSentTimestamp]), 10, 64); err == nil {
+			msg.Sent = time.UnixMilli(ms)
+		}
+		messages = append(messages, msg)
+	}
+
+	return messages, nil
+}
+
+// Delete removes a message from the queue once it's been handled.
+func (q *Queue) Delete(msg Message) error {
+	_, err := q.client.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(msg.receiptHandle),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete message %s: %w", msg.ID, err)
+	}
+
+	return nil
+}
+
+func errorText(attrs map[string]*sqs.MessageAttributeValue) string {
+	var parts []string
+	for _, name := range errorAttributes {
+		if v, ok := attrs[name]; ok && v.StringValue != nil {
+			parts = append(parts, aws.StringValue(v.StringValue))
+		}
+	}
+
+	return strings.Join(parts, ": ")
+}
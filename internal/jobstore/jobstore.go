@@ -0,0 +1,360 @@
+// Package jobstore is a durable local store of scheduled task requests kept
+// in a json file. It implements scheduler.TriggerBackend so the scheduler can
+// plan into it, and is read by the rsvperd daemon which runs the jobs when
+// they're due.
+//
+// Every change rewrites the file atomically while holding an exclusive lock
+// on a sidecar lock file, so the scheduler and the daemon can use the same
+// store at the same time.
+package jobstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+type Status string
+
+const (
+	// StatusPending jobs are waiting for their trigger time.
+	StatusPending Status = "pending"
+	// StatusRunning jobs have been claimed by a daemon.
+	StatusRunning Status = "running"
+	// StatusDone jobs finished booking.
+	StatusDone Status = "done"
+	// StatusFailed jobs finished with an error.
+	StatusFailed Status = "failed"
+	// StatusMissed jobs were due while no daemon was running and their class
+	// is no longer bookable.
+	StatusMissed Status = "missed"
+)
+
+// Job is a trigger along with its execution state.
+type Job struct {
+	Trigger   scheduler.Trigger `json:"trigger"`
+	Status    Status            `json:"status"`
+	Result    string            `json:"result,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+type file struct {
+	Jobs []Job `json:"jobs"`
+}
+
+// Store is a job store backed by a json file.
+type Store struct {
+	path string
+	// mu serializes access within the process, the lock file across
+	// processes.
+	mu sync.Mutex
+}
+
+// Open opens the store at path, creating the file if it doesn't exist.
+func Open(path string) (*Store, error) {
+	s := Store{path: path}
+	if err := s.update(func(jobs map[string]*Job) error { return nil }); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (s *Store) Create(t scheduler.Trigger) (string, error) {
+	t.ID = "local:" + t.Name
+	err := s.update(func(jobs map[string]*Job) error {
+		if _, ok := jobs[t.Name]; ok {
+			return fmt.Errorf("job %s already exists", t.Name)
+		}
+		jobs[t.Name] = &Job{Trigger: t, Status: StatusPending, UpdatedAt: time.Now()}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return t.ID, nil
+}
+
+// Update replaces the job's trigger and puts it back to pending so it runs at
+// the new time.
+func (s *Store) Update(t scheduler.Trigger) (string, error) {
+	t.ID = "local:" + t.Name
+	err := s.update(func(jobs map[string]*Job) error {
+		if _, ok := jobs[t.Name]; !ok {
+			return scheduler.ErrTriggerNotFound
+		}
+		jobs[t.Name] = &Job{Trigger: t, Status: StatusPending, UpdatedAt: time.Now()}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return t.ID, nil
+}
+
+func (s *Store) List() ([]scheduler.Trigger, error) {
+	jobs, err := s.Jobs()
+	if err != nil {
+		return nil, err
+	}
+	triggers := make([]scheduler.Trigger, len(jobs))
+	for i := range jobs {
+		triggers[i] = jobs[i].trigger()
+	}
+
+	return triggers, nil
+}
+
+func (s *Store) Get(name string) (*scheduler.Trigger, error) {
+	var trigger *scheduler.Trigger
+	err := s.view(func(jobs map[string]*Job) error {
+		job, ok := jobs[name]
+		if !ok {
+			return scheduler.ErrTriggerNotFound
+		}
+		t := job.trigger()
+		trigger = &t
+		return nil
+	})
+
+	return trigger, err
+}
+
+func (s *Store) Delete(name string) error {
+	return s.update(func(jobs map[string]*Job) error {
+		if _, ok := jobs[name]; !ok {
+			return scheduler.ErrTriggerNotFound
+		}
+		delete(jobs, name)
+		return nil
+	})
+}
+
+// Jobs returns every job ordered by trigger time.
+func (s *Store) Jobs() ([]Job, error) {
+	var list []Job
+	err := s.view(func(jobs map[string]*Job) error {
+		list = sortedJobs(jobs)
+		return nil
+	})
+
+	return list, err
+}
+
+// Claim marks the pending jobs due at now as running and returns them. A job
+// is only ever claimed once, even with several daemons using the store.
+func (s *Store) Claim(now time.Time) ([]Job, error) {
+	var claimed []Job
+	err := s.update(func(jobs map[string]*Job) error {
+		for _, job := range jobs {
+			if job.Status != StatusPending || job.Trigger.Time.After(now) {
+				continue
+			}
+			job.Status = StatusRunning
+			job.UpdatedAt = now
+			claimed = append(claimed, *job)
+		}
+		return nil
+	})
+	sort.Slice(claimed, func(i, j int) bool {
+		return claimed[i].Trigger.Time.Before(claimed[j].Trigger.Time)
+	})
+
+	return claimed, err
+}
+
+// Finish records the outcome of a claimed job.
+func (s *Store) Finish(name string, status Status, result string, jobErr error) error {
+	return s.update(func(jobs map[string]*Job) error {
+		job, ok := jobs[name]
+		if !ok {
+			return scheduler.ErrTriggerNotFound
+		}
+		job.Status = status
+		job.Result = result
+		job.Error = ""
+		if jobErr != nil {
+			job.Error = jobErr.Error()
+		}
+		job.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+// Acquire marks the caller as the store's only runner. It fails if another
+// process, e.g. a second daemon, already holds the store. The returned func
+// releases it.
+func (s *Store) Acquire() (func(), error) {
+	unlock, ok, err := tryLockFile(s.path + ".runner")
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock job store: %w", err)
+	}
+	if !ok {
+		return nil, fmt.Errorf("job store %s is already in use by another runner", s.path)
+	}
+
+	return unlock, nil
+}
+
+// Requeue puts jobs left running, e.g. by a daemon that crashed, back to
+// pending and returns how many there were. It must only be called by the
+// runner holding the store, see Acquire.
+func (s *Store) Requeue() (int, error) {
+	var n int
+	err := s.update(func(jobs map[string]*Job) error {
+		for _, job := range jobs {
+			if job.Status == StatusRunning {
+				job.Status = StatusPending
+				job.UpdatedAt = time.Now()
+				n++
+			}
+		}
+		return nil
+	})
+
+	return n, err
+}
+
+// Next returns the trigger time of the earliest pending job.
+func (s *Store) Next() (time.Time, bool, error) {
+	var (
+		next  time.Time
+		found bool
+	)
+	err := s.view(func(jobs map[string]*Job) error {
+		for _, job := range jobs {
+			if job.Status != StatusPending {
+				continue
+			}
+			if !found || job.Trigger.Time.Before(next) {
+				next, found = job.Trigger.Time, true
+			}
+		}
+		return nil
+	})
+
+	return next, found, err
+}
+
+func (j Job) trigger() scheduler.Trigger {
+	t := j.Trigger
+	t.State = string(j.Status)
+
+	return t
+}
+
+// view calls fn with the current jobs while holding the lock.
+func (s *Store) view(fn func(jobs map[string]*Job) error) error {
+	return s.withLock(func() error {
+		jobs, err := s.load()
+		if err != nil {
+			return err
+		}
+		return fn(jobs)
+	})
+}
+
+// update calls fn with the current jobs while holding the lock and saves the
+// jobs if fn succeeds.
+func (s *Store) update(fn func(jobs map[string]*Job) error) error {
+	return s.withLock(func() error {
+		jobs, err := s.load()
+		if err != nil {
+			return err
+		}
+		if err := fn(jobs); err != nil {
+			return err
+		}
+		return s.save(jobs)
+	})
+}
+
+func (s *Store) withLock(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return fmt.Errorf("unable to lock job store: %w", err)
+	}
+	defer unlock()
+
+	return fn()
+}
+
+func (s *Store) load() (map[string]*Job, error) {
+	jobs := make(map[string]*Job)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return jobs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read job store: %w", err)
+	}
+
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unable to decode job store: %w", err)
+	}
+	for i := range f.Jobs {
+		jobs[f.Jobs[i].Trigger.Name] = &f.Jobs[i]
+	}
+
+	return jobs, nil
+}
+
+// save writes the jobs to a temp file and renames it over the store so a
+// crash never leaves a partially written store behind.
+func (s *Store) save(jobs map[string]*Job) error {
+	data, err := json.MarshalIndent(file{Jobs: sortedJobs(jobs)}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode jobs: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to write jobs: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to sync jobs: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to write jobs: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("unable to replace job store: %w", err)
+	}
+
+	return nil
+}
+
+func sortedJobs(jobs map[string]*Job) []Job {
+	list := make([]Job, 0, len(jobs))
+	for _, job := range jobs {
+		list = append(list, *job)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Trigger.Time.Equal(list[j].Trigger.Time) {
+			return list[i].Trigger.Name < list[j].Trigger.Name
+		}
+		return list[i].Trigger.Time.Before(list[j].Trigger.Time)
+	})
+
+	return list
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package jobstore
+
+// lockFile is a no-op on windows, where the store is only safe to use from a
+// single process at a time.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
+
+func tryLockFile(path string) (unlock func(), ok bool, err error) {
+	return func() {}, true, nil
+}
@@ -0,0 +1,163 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const defaultChatTemplate = `{{.Headline}}`
+
+// Chat message limits, longer text is cut short.
+const (
+	slackTextLimit          = 3000
+	discordTitleLimit       = 256
+	discordDescriptionLimit = 4096
+	discordFieldLimit       = 1024
+)
+
+// Discord embed colors by event kind.
+var discordColors = map[Kind]int{
+	KindBooked:     0x2eb67d,
+	KindPromoted:   0x2eb67d,
+	KindWaitlisted: 0xecb22e,
+	KindFailed:     0xe01e5a,
+	KindCancelled:  0x868686,
+}
+
+// SlackFormat renders events for a Slack incoming webhook.
+func SlackFormat(e Event) ([]byte, error) {
+	return slack(e, e.Text())
+}
+
+// SlackTemplate is SlackFormat with the message text rendered from templates.
+func SlackTemplate(t *Templates) Format {
+	return func(e Event) ([]byte, error) {
+		text, err := t.Render(e)
+		if err != nil {
+			return nil, err
+		}
+		return slack(e, text)
+	}
+}
+
+func slack(e Event, headline string) ([]byte, error) {
+	type text struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type block struct {
+		Type   string `json:"type"`
+		Text   *text  `json:"text,omitempty"`
+		Fields []text `json:"fields,omitempty"`
+	}
+
+	// summaries span several lines and read better plain
+	headlineText := truncate(headline, slackTextLimit)
+	if e.Kind != KindSummary {
+		headlineText = "*" + truncate(headline, slackTextLimit-2) + "*"
+	}
+	blocks := []block{{Type: "section", Text: &text{Type: "mrkdwn", Text: headlineText}}}
+	if fields := chatFields(e); len(fields) > 0 {
+		b := block{Type: "section"}
+		for _, f := range fields {
+			b.Fields = append(b.Fields, text{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\n%s", f[0], f[1])})
+		}
+		blocks = append(blocks, b)
+	}
+
+	return json.Marshal(struct {
+		Text   string  `json:"text"`
+		Blocks []block `json:"blocks"`
+	}{Text: headline, Blocks: blocks})
+}
+
+// DiscordFormat renders events for a Discord webhook.
+func DiscordFormat(e Event) ([]byte, error) {
+	return discord(e, e.Text())
+}
+
+// DiscordTemplate is DiscordFormat with the embed title rendered from
+// templates.
+func DiscordTemplate(t *Templates) Format {
+	return func(e Event) ([]byte, error) {
+		title, err := t.Render(e)
+		if err != nil {
+			return nil, err
+		}
+		return discord(e, title)
+	}
+}
+
+func discord(e Event, headline string) ([]byte, error) {
+	type field struct {
+		Name   string `json:"name"`
+		Value  string `json:"value"`
+		Inline bool   `json:"inline"`
+	}
+	type embed struct {
+		Title       string  `json:"title"`
+		Description string  `json:"description,omitempty"`
+		URL         string  `json:"url,omitempty"`
+		Color       int     `json:"color,omitempty"`
+		Fields      []field `json:"fields,omitempty"`
+		Timestamp   string  `json:"timestamp,omitempty"`
+	}
+
+	em := embed{Title: truncate(headline, discordTitleLimit), URL: e.Class.Link(), Color: discordColors[e.Kind]}
+	if e.Kind == KindSummary {
+		// titles are a single line, the rest of the summary goes in the
+		// description
+		title, rest, _ := strings.Cut(headline, "\n")
+		em.Title = truncate(title, discordTitleLimit)
+		em.Description = truncate(rest, discordDescriptionLimit)
+	}
+	if !e.Time.IsZero() {
+		em.Timestamp = e.Time.Format(time.RFC3339)
+	}
+	for _, f := range chatFields(e) {
+		em.Fields = append(em.Fields, field{Name: f[0], Value: truncate(f[1], discordFieldLimit), Inline: f[0] != "Error"})
+	}
+
+	return json.Marshal(struct {
+		Embeds []embed `json:"embeds"`
+	}{Embeds: []embed{em}})
+}
+
+// truncate cuts s to at most limit characters, ending it with an ellipsis
+// when it's cut.
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+
+	return string(runes[:limit-1]) + "…"
+}
+
+// chatFields are the name and value pairs chat messages show for an event.
+func chatFields(e Event) [][2]string {
+	if e.Kind == KindSummary {
+		return nil
+	}
+
+	fields := [][2]string{
+		{"Class", ClassTitle(e.Class)},
+		{"Time", formatStart(e.Class)},
+	}
+	if e.Status != "" {
+		fields = append(fields, [2]string{"Status", e.Status})
+	}
+	if e.Attempts > 0 {
+		fields = append(fields, [2]string{"Attempts", fmt.Sprint(e.Attempts)})
+	}
+	if e.Latency > 0 {
+		fields = append(fields, [2]string{"Latency", e.Latency.Round(time.Millisecond).String()})
+	}
+	if e.Error != "" {
+		fields = append(fields, [2]string{"Error", strings.TrimSpace(e.Error)})
+	}
+
+	return fields
+}
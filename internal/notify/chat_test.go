@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type slackPayload struct {
+	Text   string `json:"text"`
+	Blocks []struct {
+		Type string `json:"type"`
+		Text *struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"text"`
+		Fields []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"fields"`
+	} `json:"blocks"`
+}
+
+type discordPayload struct {
+	Embeds []struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		URL         string `json:"url"`
+		Color       int    `json:"color"`
+		Fields      []struct {
+			Name   string `json:"name"`
+			Value  string `json:"value"`
+			Inline bool   `json:"inline"`
+		} `json:"fields"`
+		Timestamp string `json:"timestamp"`
+	} `json:"embeds"`
+}
+
+// post sends the event through a webhook with format and decodes what the
+// receiver got into payload.
+func post(t *testing.T, format Format, e Event, payload interface{}) {
+	t.Helper()
+	r := &receiver{}
+	w := newTestWebhook(t, r, format)
+	if err := w.Notify(context.Background(), e); err != nil {
+		t.Fatalf("unable to notify: %v", err)
+	}
+	if err := json.Unmarshal(r.bodies[0], payload); err != nil {
+		t.Fatalf("unable to decode payload %s: %v", r.bodies[0], err)
+	}
+}
+
+func TestSlackFormat(t *testing.T) {
+	var got slackPayload
+	post(t, SlackFormat, testEvent(KindBooked), &got)
+
+	want := "booked CrossFit Small Group at Tue Oct 20 5:30PM"
+	if got.Text != want {
+		t.Errorf("got text %q, want %q", got.Text, want)
+	}
+	if len(got.Blocks) != 2 {
+		t.Fatalf("got %d blocks, want a headline and fields", len(got.Blocks))
+	}
+	if got.Blocks[0].Text == nil || got.Blocks[0].Text.Type != "mrkdwn" || got.Blocks[0].Text.Text != "*"+want+"*" {
+		t.Errorf("got headline %+v, want %q in bold", got.Blocks[0].Text, want)
+	}
+	fields := map[string]bool{}
+	for _, f := range got.Blocks[1].Fields {
+		fields[f.Text] = true
+	}
+	for _, f := range []string{"*Class*\nCrossFit Small Group", "*Status*\nrsvped", "*Attempts*\n2", "*Latency*\n1.5s"} {
+		if !fields[f] {
+			t.Errorf("got fields %+v, missing %q", got.Blocks[1].Fields, f)
+		}
+	}
+}
+
+func TestSlackSummary(t *testing.T) {
+	var got slackPayload
+	post(t, SlackFormat, Event{Kind: KindSummary, Message: "rsvper plan:\n  created 2"}, &got)
+
+	if len(got.Blocks) != 1 {
+		t.Fatalf("got %d blocks, want just the summary", len(got.Blocks))
+	}
+	if got.Blocks[0].Text.Text != "rsvper plan:\n  created 2" {
+		t.Errorf("got %q, want the summary as plain text", got.Blocks[0].Text.Text)
+	}
+}
+
+func TestDiscordFormat(t *testing.T) {
+	var got discordPayload
+	post(t, DiscordFormat, testEvent(KindFailed), &got)
+
+	if len(got.Embeds) != 1 {
+		t.Fatalf("got %d embeds, want 1", len(got.Embeds))
+	}
+	em := got.Embeds[0]
+	if em.URL != "https://crossfit-austin.triib.com/schedule/42/" {
+		t.Errorf("got url %q, want the absolute class page", em.URL)
+	}
+	if em.Color != discordColors[KindFailed] {
+		t.Errorf("got color %#x, want %#x", em.Color, discordColors[KindFailed])
+	}
+	if em.Timestamp != "2026-10-15T17:30:00Z" {
+		t.Errorf("got timestamp %q", em.Timestamp)
+	}
+	if !strings.HasPrefix(em.Title, "unable to book CrossFit Small Group") {
+		t.Errorf("got title %q", em.Title)
+	}
+	if len(em.Fields) == 0 || em.Fields[0].Name != "Class" || !em.Fields[0].Inline {
+		t.Errorf("got fields %+v, want the class first", em.Fields)
+	}
+}
+
+func TestDiscordSummary(t *testing.T) {
+	message := "rsvper weekly digest\n" + strings.Repeat("x", 5000)
+	var got discordPayload
+	post(t, DiscordFormat, Event{Kind: KindSummary, Message: message}, &got)
+
+	em := got.Embeds[0]
+	if em.Title != "rsvper weekly digest" {
+		t.Errorf("got title %q, want the first line", em.Title)
+	}
+	if n := len([]rune(em.Description)); n != discordDescriptionLimit {
+		t.Errorf("got a %d character description, want it cut to %d", n, discordDescriptionLimit)
+	}
+	if !strings.HasPrefix(em.Description, "xxx") {
+		t.Errorf("got description %.40q, want the summary after the title", em.Description)
+	}
+	if em.URL != "" || len(em.Fields) > 0 {
+		t.Errorf("got url %q and fields %+v for a summary", em.URL, em.Fields)
+	}
+}
+
+func TestDiscordLongError(t *testing.T) {
+	e := testEvent(KindFailed)
+	e.Error = strings.Repeat("x", 2000)
+	var got discordPayload
+	post(t, DiscordFormat, e, &got)
+
+	em := got.Embeds[0]
+	last := em.Fields[len(em.Fields)-1]
+	if last.Name != "Error" {
+		t.Fatalf("got fields %+v, want the error last", em.Fields)
+	}
+	if n := len([]rune(last.Value)); n != discordFieldLimit {
+		t.Errorf("got a %d character error, want it cut to %d", n, discordFieldLimit)
+	}
+}
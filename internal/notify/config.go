@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/itsHabib/rsvper/internal/config"
+)
+
+// envSMTPPassword holds the password for the email notifier's SMTP server.
+const envSMTPPassword = "RSVPER_SMTP_PASSWORD"
+
+// FromConfig returns a dispatcher holding every notifier set up in the
+// config, each filtered by the notification preferences.
+func FromConfig(cfg *config.Config) (*Dispatcher, error) {
+	prefs := Preferences{Only: cfg.Notify.Preferences.Only, Location: cfg.Location()}
+	if q := cfg.Notify.Preferences.QuietHours; q != nil {
+		start, end, err := q.Parse()
+		if err != nil {
+			return nil, err
+		}
+		prefs.QuietStart, prefs.QuietEnd = start, end
+	}
+
+	d := NewDispatcher()
+	add := func(n Notifier, minSeverity string) error {
+		p := prefs
+		severity, err := ParseSeverity(minSeverity)
+		if err != nil {
+			return err
+		}
+		p.MinSeverity = severity
+		d.Add(NewFiltered(n, p))
+		return nil
+	}
+
+	if t := cfg.Notify.Twilio; t != nil {
+		n, err := NewTwilio(t.From, t.To)
+		if err != nil {
+			return nil, err
+		}
+		if err := n.SetTemplates(t.Templates); err != nil {
+			return nil, err
+		}
+		if err := add(n, t.MinSeverity); err != nil {
+			return nil, err
+		}
+	}
+	if e := cfg.Notify.Email; e != nil {
+		n, err := NewEmail(EmailConfig{
+			Host:     e.Host,
+			Port:     e.Port,
+			Username: e.Username,
+			Password: os.Getenv(envSMTPPassword),
+			From:     e.From,
+			To:       e.To,
+			StartTLS: !e.Insecure,
+		})
+		if err != nil {
+			return nil, err
+		}
+		subject, text, html := make(map[string]string), make(map[string]string), make(map[string]string)
+		for kind, t := range e.Templates {
+			setIf(subject, kind, t.Subject)
+			setIf(text, kind, t.Text)
+			setIf(html, kind, t.HTML)
+		}
+		if err := n.SetTemplates(subject, text, html); err != nil {
+			return nil, err
+		}
+		if err := add(n, e.MinSeverity); err != nil {
+			return nil, err
+		}
+	}
+	for _, w := range cfg.Notify.Webhooks {
+		format, err := webhookFormat(w)
+		if err != nil {
+			return nil, err
+		}
+		n, err := NewWebhook(w.URL, format)
+		if err != nil {
+			return nil, err
+		}
+		if w.SecretEnv != "" {
+			secret := os.Getenv(w.SecretEnv)
+			if secret == "" {
+				return nil, fmt.Errorf("webhook secret %s is not set", w.SecretEnv)
+			}
+			n.SetSecret(secret)
+		}
+		if w.Attempts > 0 {
+			n.SetAttempts(w.Attempts)
+		}
+		if err := add(n, w.MinSeverity); err != nil {
+			return nil, err
+		}
+	}
+
+	return d, nil
+}
+
+func webhookFormat(w config.Webhook) (Format, error) {
+	if w.Format != config.WebhookSlack && w.Format != config.WebhookDiscord {
+		if len(w.Templates) > 0 {
+			return nil, fmt.Errorf("webhook templates need the %s or %s format", config.WebhookSlack, config.WebhookDiscord)
+		}
+		return JSONFormat, nil
+	}
+
+	t, err := ParseTemplates(w.Format, defaultChatTemplate, w.Templates)
+	if err != nil {
+		return nil, err
+	}
+	if w.Format == config.WebhookSlack {
+		return SlackTemplate(t), nil
+	}
+
+	return DiscordTemplate(t), nil
+}
+
+func setIf(m map[string]string, key, value string) {
+	if value != "" {
+		m[key] = value
+	}
+}
@@ -0,0 +1,318 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSubjectTemplate = `[rsvper] {{.Headline}}`
+	defaultTextTemplate    = `{{.Headline}}
+{{if .Class.Title}}
+Class:   {{.Title}}
+Coaches: {{.Class.Coaches}}
+Time:    {{.Start}}
+{{- end}}
+{{- if .Status}}
+Status:  {{.Status}}
+{{- end}}
+{{- if .Error}}
+Error:   {{.Error}}
+{{- end}}
+{{- if .Message}}
+
+{{.Message}}
+{{- end}}
+`
+	defaultHTMLTemplate = `<html><body>
+<p><strong>{{.Headline}}</strong></p>
+{{if .Class.Title}}<table>
+<tr><td>Class</td><td>{{.Title}}</td></tr>
+<tr><td>Coaches</td><td>{{.Class.Coaches}}</td></tr>
+<tr><td>Time</td><td>{{.Start}}</td></tr>
+{{if .Status}}<tr><td>Status</td><td>{{.Status}}</td></tr>{{end}}
+{{if .Error}}<tr><td>Error</td><td>{{.Error}}</td></tr>{{end}}
+</table>{{end}}
+{{if .Message}}<pre>{{.Message}}</pre>{{end}}
+</body></html>
+`
+
+	// defaultClassLength is used for invites when the class has no end.
+	defaultClassLength = time.Hour
+)
+
+// EmailConfig is how the email notifier reaches its SMTP server.
+type EmailConfig struct {
+	Host string
+	Port int
+	// Username and Password authenticate with PLAIN auth. Auth is skipped
+	// when Username is empty.
+	Username string
+	Password string
+	From     string
+	To       []string
+	// StartTLS upgrades the connection before authenticating and fails if
+	// the server doesn't support it. Only turn it off for local test servers.
+	StartTLS bool
+}
+
+// Email sends events as multipart emails with a plain text and an html body.
+// Booked and promoted classes get an .ics invite attached.
+type Email struct {
+	cfg     EmailConfig
+	subject *Templates
+	text    *Templates
+	html    *Templates
+}
+
+func NewEmail(cfg EmailConfig) (*Email, error) {
+	if cfg.Host == "" {
+		return nil, fmt.Errorf("smtp host cannot be empty")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	if cfg.From == "" {
+		return nil, fmt.Errorf("email from address cannot be empty")
+	}
+	if len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email needs at least one recipient")
+	}
+
+	m := Email{cfg: cfg}
+	if err := m.SetTemplates(nil, nil, nil); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// SetTemplates overrides the subject, text and html templates by event kind.
+// Kinds without an override use the default templates.
+func (m *Email) SetTemplates(subject, text, html map[string]string) error {
+	s, err := ParseTemplates("subject", defaultSubjectTemplate, subject)
+	if err != nil {
+		return err
+	}
+	t, err := ParseTemplates("text", defaultTextTemplate, text)
+	if err != nil {
+		return err
+	}
+	h, err := ParseHTMLTemplates("html", defaultHTMLTemplate, html)
+	if err != nil {
+		return err
+	}
+	m.subject, m.text, m.html = s, t, h
+
+	return nil
+}
+
+func (m *Email) Notify(ctx context.Context, e Event) error {
+	msg, err := m.message(e)
+	if err != nil {
+		return err
+	}
+
+	return m.send(ctx, msg)
+}
+
+// message renders the event as a complete MIME message.
+func (m *Email) message(e Event) ([]byte, error) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	subject, err := m.subject.Render(e)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render subject: %w", err)
+	}
+	text, err := m.text.Render(e)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render text body: %w", err)
+	}
+	html, err := m.html.Render(e)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render html body: %w", err)
+	}
+
+	var buf bytes.Buffer
+	mixed := multipart.NewWriter(&buf)
+	header := []string{
+		"From: " + m.cfg.From,
+		"To: " + strings.Join(m.cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)),
+		"Date: " + e.Time.Format(time.RFC1123Z),
+		"Message-ID: " + messageID(m.cfg.From),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/mixed; boundary=" + mixed.Boundary(),
+	}
+	buf.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	// the bodies are alternatives nested inside the mixed part
+	var alt bytes.Buffer
+	altWriter := multipart.NewWriter(&alt)
+	if err := writeQuotedPrintable(altWriter, "text/plain; charset=utf-8", []byte(text)); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(altWriter, "text/html; charset=utf-8", []byte(html)); err != nil {
+		return nil, err
+	}
+	if err := altWriter.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write email body: %w", err)
+	}
+	part, err := mixed.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {"multipart/alternative; boundary=" + altWriter.Boundary()},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to write email body: %w", err)
+	}
+	if _, err := part.Write(alt.Bytes()); err != nil {
+		return nil, fmt.Errorf("unable to write email body: %w", err)
+	}
+
+	if (e.Kind == KindBooked || e.Kind == KindPromoted) && e.Class.Start != nil {
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {`text/calendar; charset=utf-8; method=PUBLISH; name="class.ics"`},
+			"Content-Disposition": {`attachment; filename="class.ics"`},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to write invite: %w", err)
+		}
+		if _, err := part.Write(invite(e)); err != nil {
+			return nil, fmt.Errorf("unable to write invite: %w", err)
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write email: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// send delivers the message over SMTP, upgrading to TLS and authenticating
+// when configured.
+func (m *Email) send(ctx context.Context, msg []byte) error {
+	addr := net.JoinHostPort(m.cfg.Host, strconv.Itoa(m.cfg.Port))
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("unable to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, m.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("unable to start smtp session: %w", err)
+	}
+	defer c.Close()
+
+	if m.cfg.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s doesn't support STARTTLS", addr)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: m.cfg.Host}); err != nil {
+			return fmt.Errorf("unable to start tls: %w", err)
+		}
+	}
+	if m.cfg.Username != "" {
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("unable to authenticate: %w", err)
+		}
+	}
+
+	if err := c.Mail(m.cfg.From); err != nil {
+		return fmt.Errorf("unable to set sender: %w", err)
+	}
+	for _, to := range m.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf("unable to add recipient %s: %w", to, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("unable to start message: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("unable to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("unable to send message: %w", err)
+	}
+
+	return c.Quit()
+}
+
+func writeQuotedPrintable(w *multipart.Writer, contentType string, body []byte) error {
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {contentType},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to write email body: %w", err)
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(body); err != nil {
+		return fmt.Errorf("unable to write email body: %w", err)
+	}
+
+	return qp.Close()
+}
+
+// invite returns an iCalendar event for the class.
+func invite(e Event) []byte {
+	const layout = "20060102T150405Z"
+	start := e.Class.Start.UTC()
+	end := start.Add(defaultClassLength)
+	if e.Class.End != nil {
+		end = e.Class.End.UTC()
+	}
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//rsvper//rsvper//EN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		fmt.Sprintf("UID:class-%d@rsvper", e.Class.ID),
+		"DTSTAMP:" + e.Time.UTC().Format(layout),
+		"DTSTART:" + start.Format(layout),
+		"DTEND:" + end.Format(layout),
+		"SUMMARY:" + escapeICS(ClassTitle(e.Class)),
+		"DESCRIPTION:" + escapeICS(fmt.Sprintf("Coaches: %s\nStatus: %s", e.Class.Coaches, e.Status)),
+	}
+	if link := e.Class.Link(); link != "" {
+		lines = append(lines, "URL:"+escapeICS(link))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func escapeICS(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+func messageID(from string) string {
+	domain := "rsvper"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = strings.Trim(from[i+1:], "> ")
+	}
+	b := make([]byte, 12)
+	rand.Read(b)
+
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b), domain)
+}
@@ -0,0 +1,196 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// smtpSink accepts one SMTP session on a local listener and returns the
+// message it was sent.
+func smtpSink(t *testing.T) (int, <-chan []byte) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan []byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := textproto.NewConn(conn)
+		c.PrintfLine("220 localhost ready")
+		for {
+			line, err := c.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.Fields(line + " ")[0]); verb {
+			case "EHLO", "HELO":
+				c.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				c.PrintfLine("250 ok")
+			case "DATA":
+				c.PrintfLine("354 go ahead")
+				msg, err := c.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				messages <- msg
+				c.PrintfLine("250 queued")
+			case "QUIT":
+				c.PrintfLine("221 bye")
+				return
+			default:
+				c.PrintfLine("502 %s not implemented", verb)
+			}
+		}
+	}()
+
+	return l.Addr().(*net.TCPAddr).Port, messages
+}
+
+func TestEmail(t *testing.T) {
+	port, messages := smtpSink(t)
+	m, err := NewEmail(EmailConfig{Host: "127.0.0.1", Port: port, From: "rsvper@example.com", To: []string{"me@example.com"}})
+	if err != nil {
+		t.Fatalf("unable to create email notifier: %v", err)
+	}
+
+	loc, err := time.LoadLocation(cfa.Timezone)
+	if err != nil {
+		t.Fatalf("unable to load timezone: %v", err)
+	}
+	start := time.Date(2026, 10, 20, 17, 30, 0, 0, loc)
+	end := start.Add(45 * time.Minute)
+	e := Event{
+		Kind:   KindBooked,
+		Class:  cfa.Schedule{ID: 42, Title: "CrossFit Small Group", Coaches: "Sam", Start: &start, End: &end, URL: "/schedule/42/"},
+		Status: "rsvped",
+		Time:   start.Add(-120 * time.Hour),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := m.Notify(ctx, e); err != nil {
+		t.Fatalf("unable to send email: %v", err)
+	}
+
+	var raw []byte
+	select {
+	case raw = <-messages:
+	case <-ctx.Done():
+		t.Fatal("the sink got no message")
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unable to parse message: %v", err)
+	}
+	if got := msg.Header.Get("Subject"); got != "[rsvper] booked CrossFit Small Group at Tue Oct 20 5:30PM" {
+		t.Errorf("got subject %q", got)
+	}
+
+	parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body)
+	if len(parts) != 2 {
+		t.Fatalf("got %d parts, want the bodies and the invite", len(parts))
+	}
+	bodies := readParts(t, parts[0].contentType, bytes.NewReader(parts[0].body))
+	if len(bodies) != 2 {
+		t.Fatalf("got %d bodies, want text and html", len(bodies))
+	}
+	if !strings.HasPrefix(bodies[0].contentType, "text/plain") || !strings.Contains(string(bodies[0].body), "Coaches: Sam") {
+		t.Errorf("got text body %s %q", bodies[0].contentType, bodies[0].body)
+	}
+	if !strings.HasPrefix(bodies[1].contentType, "text/html") || !strings.Contains(string(bodies[1].body), "<td>Sam</td>") {
+		t.Errorf("got html body %s %q", bodies[1].contentType, bodies[1].body)
+	}
+
+	ics := parts[1]
+	if _, params, err := mime.ParseMediaType(ics.disposition); err != nil || params["filename"] != "class.ics" {
+		t.Errorf("got disposition %q, want a class.ics attachment", ics.disposition)
+	}
+	if !strings.HasPrefix(ics.contentType, "text/calendar") {
+		t.Errorf("got invite type %q", ics.contentType)
+	}
+	// the sink's dot reader turns line endings into \n
+	lines := map[string]bool{}
+	for _, line := range strings.Split(string(ics.body), "\n") {
+		lines[strings.TrimSuffix(line, "\r")] = true
+	}
+	for _, line := range []string{
+		"DTSTART:20261020T223000Z",
+		"DTEND:20261020T231500Z",
+		"UID:class-42@rsvper",
+		"URL:https://crossfit-austin.triib.com/schedule/42/",
+	} {
+		if !lines[line] {
+			t.Errorf("got invite %q, missing %s", ics.body, line)
+		}
+	}
+}
+
+func TestEmailInviteOnlyForBookings(t *testing.T) {
+	m, err := NewEmail(EmailConfig{Host: "localhost", From: "rsvper@example.com", To: []string{"me@example.com"}})
+	if err != nil {
+		t.Fatalf("unable to create email notifier: %v", err)
+	}
+	start := time.Now()
+	raw, err := m.message(Event{Kind: KindFailed, Class: cfa.Schedule{ID: 42, Start: &start}, Error: "full"})
+	if err != nil {
+		t.Fatalf("unable to render message: %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("unable to parse message: %v", err)
+	}
+	if parts := readParts(t, msg.Header.Get("Content-Type"), msg.Body); len(parts) != 1 {
+		t.Errorf("got %d parts, want just the bodies", len(parts))
+	}
+}
+
+type part struct {
+	contentType string
+	disposition string
+	body        []byte
+}
+
+// readParts reads the parts of a multipart body, decoding quoted-printable.
+func readParts(t *testing.T, contentType string, body io.Reader) []part {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("got content type %q, want multipart", contentType)
+	}
+
+	var parts []part
+	r := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("unable to read part: %v", err)
+		}
+		data, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatalf("unable to read part: %v", err)
+		}
+		parts = append(parts, part{contentType: p.Header.Get("Content-Type"), disposition: p.Header.Get("Content-Disposition"), body: data})
+	}
+}
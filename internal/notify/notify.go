@@ -0,0 +1,146 @@
+// Package notify tells people what happened to their bookings. Outcomes are
+// structured events that a dispatcher fans out to every configured notifier,
+// e.g. Twilio text messages or email.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// Kind is what happened.
+type Kind string
+
+const (
+	// KindBooked is sent when the account got a spot in the class.
+	KindBooked Kind = "booked"
+	// KindWaitlisted is sent when the class was full and the account is on
+	// the wait list.
+	KindWaitlisted Kind = "waitlisted"
+	// KindFailed is sent when a booking couldn't be completed.
+	KindFailed Kind = "failed"
+	// KindPromoted is sent when the account moved from the wait list to a
+	// spot in the class.
+	KindPromoted Kind = "promoted"
+	// KindCancelled is sent when the account's spot was given up.
+	KindCancelled Kind = "cancelled"
+	// KindSummary carries a plan or digest summary in Message rather than a
+	// single class outcome.
+	KindSummary Kind = "summary"
+)
+
+// Event is a booking outcome.
+type Event struct {
+	Kind Kind `json:"kind"`
+	// Class is the class the event is about. It's empty for summaries.
+	Class cfa.Schedule `json:"class"`
+	// Account is the username the class was booked for.
+	Account string `json:"account,omitempty"`
+	// Status is the account's rsvp status for the class after the event.
+	Status string `json:"status,omitempty"`
+	// Attempts is how many register requests the booking made.
+	Attempts int `json:"attempts,omitempty"`
+	// Latency is how long after the rsvp window opened the booking finished.
+	Latency time.Duration `json:"latency,omitempty"`
+	// Error is why a booking failed.
+	Error string `json:"error,omitempty"`
+	// Message is free form detail, the whole text for summaries.
+	Message string    `json:"message,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// Text renders the event as a short plain text message.
+func (e Event) Text() string {
+	class := fmt.Sprintf("%s at %s", ClassTitle(e.Class), formatStart(e.Class))
+	switch e.Kind {
+	case KindBooked:
+		return fmt.Sprintf("booked %s", class)
+	case KindWaitlisted:
+		return fmt.Sprintf("waitlisted for %s", class)
+	case KindPromoted:
+		return fmt.Sprintf("promoted off the wait list for %s", class)
+	case KindCancelled:
+		if e.Message != "" {
+			return fmt.Sprintf("cancelled %s: %s", class, e.Message)
+		}
+		return fmt.Sprintf("cancelled %s", class)
+	case KindFailed:
+		return fmt.Sprintf("unable to book %s: %s", class, e.Error)
+	default:
+		return e.Message
+	}
+}
+
+// ClassTitle returns the class title on a single line.
+func ClassTitle(sched cfa.Schedule) string {
+	return strings.Replace(sched.Title, "\n", " ", 1)
+}
+
+func formatStart(sched cfa.Schedule) string {
+	if sched.Start == nil {
+		return "unknown time"
+	}
+
+	return sched.Start.Format("Mon Jan 2 3:04PM")
+}
+
+// Notifier delivers events over one channel.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// Dispatcher fans events out to every notifier it holds. It implements
+// Notifier itself, and one without notifiers drops events.
+type Dispatcher struct {
+	notifiers []Notifier
+}
+
+func NewDispatcher(notifiers ...Notifier) *Dispatcher {
+	return &Dispatcher{notifiers: notifiers}
+}
+
+// Add adds a notifier to the dispatcher.
+func (d *Dispatcher) Add(n Notifier) {
+	d.notifiers = append(d.notifiers, n)
+}
+
+// Len returns the number of notifiers.
+func (d *Dispatcher) Len() int {
+	return len(d.notifiers)
+}
+
+// Notify sends the event to every notifier concurrently. A failing notifier
+// doesn't stop the others, their errors are returned together.
+func (d *Dispatcher) Notify(ctx context.Context, e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	errs := make([]error, len(d.notifiers))
+	var wg sync.WaitGroup
+	for i := range d.notifiers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = d.notifiers[i].Notify(ctx, e)
+		}(i)
+	}
+	wg.Wait()
+
+	var msgs []string
+	for _, err := range errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	if len(msgs) > 0 {
+		return fmt.Errorf("unable to notify: %s", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Severity is how much an event needs attention.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	default:
+		return "info"
+	}
+}
+
+// ParseSeverity parses info, warning or error. Empty is info.
+func ParseSeverity(s string) (Severity, error) {
+	switch s {
+	case "", "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	default:
+		return 0, fmt.Errorf("invalid severity %q, expected info, warning or error", s)
+	}
+}
+
+// Severity returns how much events of the kind need attention: failures are
+// errors, wait lists and cancellations warnings, everything else info.
+func (k Kind) Severity() Severity {
+	switch k {
+	case KindFailed:
+		return SeverityError
+	case KindWaitlisted, KindCancelled:
+		return SeverityWarning
+	default:
+		return SeverityInfo
+	}
+}
+
+// Success reports whether the event is a class the account got into or is
+// waiting on.
+func (k Kind) Success() bool {
+	return k == KindBooked || k == KindWaitlisted || k == KindPromoted
+}
+
+// Which events to send.
+const (
+	OnlyFailures  = "failures"
+	OnlySuccesses = "successes"
+)
+
+// Preferences decide which events a notifier is sent.
+type Preferences struct {
+	// Only is OnlyFailures or OnlySuccesses to send just those, empty for
+	// every event. Summaries are sent either way.
+	Only string
+	// QuietStart and QuietEnd are times of day, as offsets from midnight in
+	// Location, between which only failures are sent. Everything else is
+	// dropped, summaries included. The range can wrap past midnight. Quiet
+	// hours are off when they're equal.
+	QuietStart time.Duration
+	QuietEnd   time.Duration
+	Location   *time.Location
+	// MinSeverity drops events below it.
+	MinSeverity Severity
+}
+
+// Allows reports whether the event should be sent.
+func (p Preferences) Allows(e Event) bool {
+	// summaries cover failures and successes alike
+	switch {
+	case e.Kind == KindSummary:
+	case p.Only == OnlyFailures:
+		if e.Kind != KindFailed {
+			return false
+		}
+	case p.Only == OnlySuccesses:
+		if !e.Kind.Success() {
+			return false
+		}
+	}
+	if e.Kind.Severity() < p.MinSeverity {
+		return false
+	}
+	// a failed booking can still be fixed by hand, so it can't wait out
+	// quiet hours
+	if e.Kind.Severity() >= SeverityError {
+		return true
+	}
+
+	return !p.quiet(e.Time)
+}
+
+func (p Preferences) quiet(t time.Time) bool {
+	if p.QuietStart == p.QuietEnd {
+		return false
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if p.Location != nil {
+		t = t.In(p.Location)
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	now := t.Sub(midnight)
+	if p.QuietStart < p.QuietEnd {
+		return now >= p.QuietStart && now < p.QuietEnd
+	}
+
+	return now >= p.QuietStart || now < p.QuietEnd
+}
+
+// Filtered only passes the events its preferences allow to a notifier.
+type Filtered struct {
+	notifier Notifier
+	prefs    Preferences
+}
+
+func NewFiltered(n Notifier, prefs Preferences) *Filtered {
+	return &Filtered{notifier: n, prefs: prefs}
+}
+
+func (f *Filtered) Notify(ctx context.Context, e Event) error {
+	if !f.prefs.Allows(e) {
+		return nil
+	}
+
+	return f.notifier.Notify(ctx, e)
+}
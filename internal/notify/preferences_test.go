@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPreferencesAllows(t *testing.T) {
+	loc, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatalf("unable to load timezone: %v", err)
+	}
+	at := func(hour int) time.Time {
+		return time.Date(2026, 10, 20, hour, 0, 0, 0, loc)
+	}
+	// 10pm to 7am, wrapping past midnight
+	quiet := Preferences{QuietStart: 22 * time.Hour, QuietEnd: 7 * time.Hour, Location: loc}
+
+	tests := []struct {
+		name  string
+		prefs Preferences
+		event Event
+		want  bool
+	}{
+		{name: "no preferences", event: Event{Kind: KindBooked, Time: at(6)}, want: true},
+		{name: "only failures drops bookings", prefs: Preferences{Only: OnlyFailures}, event: Event{Kind: KindBooked}, want: false},
+		{name: "only failures sends failures", prefs: Preferences{Only: OnlyFailures}, event: Event{Kind: KindFailed}, want: true},
+		{name: "only successes sends wait lists", prefs: Preferences{Only: OnlySuccesses}, event: Event{Kind: KindWaitlisted}, want: true},
+		{name: "only successes drops cancellations", prefs: Preferences{Only: OnlySuccesses}, event: Event{Kind: KindCancelled}, want: false},
+		{name: "only sends summaries", prefs: Preferences{Only: OnlyFailures}, event: Event{Kind: KindSummary}, want: true},
+		{name: "below min severity", prefs: Preferences{MinSeverity: SeverityWarning}, event: Event{Kind: KindBooked}, want: false},
+		{name: "at min severity", prefs: Preferences{MinSeverity: SeverityWarning}, event: Event{Kind: KindCancelled}, want: true},
+		{name: "before quiet hours", prefs: quiet, event: Event{Kind: KindBooked, Time: at(21)}, want: true},
+		{name: "in quiet hours before midnight", prefs: quiet, event: Event{Kind: KindBooked, Time: at(22)}, want: false},
+		{name: "in quiet hours after midnight", prefs: quiet, event: Event{Kind: KindWaitlisted, Time: at(6)}, want: false},
+		{name: "after quiet hours", prefs: quiet, event: Event{Kind: KindBooked, Time: at(7)}, want: true},
+		{name: "summary in quiet hours", prefs: quiet, event: Event{Kind: KindSummary, Time: at(6)}, want: false},
+		{name: "failure in quiet hours", prefs: quiet, event: Event{Kind: KindFailed, Time: at(6)}, want: true},
+		{name: "quiet hours in another zone", prefs: quiet, event: Event{Kind: KindBooked, Time: at(21).UTC()}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.prefs.Allows(tt.event); got != tt.want {
+				t.Errorf("got %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"text/template"
+)
+
+// DefaultTemplate is the key of the template used for kinds without their own.
+const DefaultTemplate = "default"
+
+// TemplateData is what notification templates are executed with. Besides the
+// event's own fields, Kind, Class (ID, Title, Coaches, Start, End, URL),
+// Account, Status, Attempts, Latency, Error, Message and Time, it has:
+//
+//	.Headline  the event's one line text, e.g. "booked CrossFit at Tue Oct 20 6:30AM"
+//	.Title     the class title on a single line
+//	.Start     the class start time, e.g. "Tue Oct 20 6:30AM"
+//	.Severity  info, warning or error
+type TemplateData struct {
+	Event
+	Headline string
+	Title    string
+	Start    string
+	Severity Severity
+}
+
+func newTemplateData(e Event) TemplateData {
+	return TemplateData{
+		Event:    e,
+		Headline: e.Text(),
+		Title:    ClassTitle(e.Class),
+		Start:    formatStart(e.Class),
+		Severity: e.Kind.Severity(),
+	}
+}
+
+type executor interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// Templates renders events with a template per event kind, falling back to
+// the default template.
+type Templates struct {
+	byKind map[Kind]executor
+}
+
+// ParseTemplates parses a default text template and overrides keyed by event
+// kind or DefaultTemplate.
+func ParseTemplates(name, def string, overrides map[string]string) (*Templates, error) {
+	return parseTemplates(name, def, overrides, func(name, text string) (executor, error) {
+		return template.New(name).Parse(text)
+	})
+}
+
+// ParseHTMLTemplates is ParseTemplates for html, escaping event fields.
+func ParseHTMLTemplates(name, def string, overrides map[string]string) (*Templates, error) {
+	return parseTemplates(name, def, overrides, func(name, text string) (executor, error) {
+		return htmltemplate.New(name).Parse(text)
+	})
+}
+
+func parseTemplates(name, def string, overrides map[string]string, parse func(name, text string) (executor, error)) (*Templates, error) {
+	t := Templates{byKind: make(map[Kind]executor)}
+	texts := map[string]string{DefaultTemplate: def}
+	for key, text := range overrides {
+		if key != DefaultTemplate && !validKind(Kind(key)) {
+			return nil, fmt.Errorf("invalid %s template %q, expected an event kind or %s", name, key, DefaultTemplate)
+		}
+		texts[key] = text
+	}
+	for key, text := range texts {
+		tmpl, err := parse(name+"."+key, text)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s template %q: %w", name, key, err)
+		}
+		t.byKind[Kind(key)] = tmpl
+	}
+
+	return &t, nil
+}
+
+// Render executes the event kind's template.
+func (t *Templates) Render(e Event) (string, error) {
+	tmpl, ok := t.byKind[e.Kind]
+	if !ok {
+		tmpl = t.byKind[DefaultTemplate]
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, newTemplateData(e)); err != nil {
+		return "", fmt.Errorf("unable to render %s event: %w", e.Kind, err)
+	}
+
+	return buf.String(), nil
+}
+
+func validKind(k Kind) bool {
+	switch k {
+	case KindBooked, KindWaitlisted, KindFailed, KindPromoted, KindCancelled, KindSummary:
+		return true
+	default:
+		return false
+	}
+}
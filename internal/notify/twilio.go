@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+const defaultSMSTemplate = `{{.Headline}}`
+
+// Twilio texts events to a list of phone numbers. The account credentials
+// are read from the TWILIO_ACCOUNT_SID and TWILIO_AUTH_TOKEN environment
+// variables.
+type Twilio struct {
+	client *twilio.RestClient
+	from   string
+	to     []string
+	text   *Templates
+}
+
+func NewTwilio(from string, to []string) (*Twilio, error) {
+	if from == "" {
+		return nil, fmt.Errorf("twilio from number cannot be empty")
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("twilio needs at least one recipient")
+	}
+
+	t := Twilio{client: twilio.NewRestClient(), from: from, to: to}
+	if err := t.SetTemplates(nil); err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// SetTemplates overrides the text by event kind. Kinds without an override
+// are sent as the event's headline.
+func (t *Twilio) SetTemplates(text map[string]string) error {
+	tmpl, err := ParseTemplates("sms", defaultSMSTemplate, text)
+	if err != nil {
+		return err
+	}
+	t.text = tmpl
+
+	return nil
+}
+
+func (t *Twilio) Notify(_ context.Context, e Event) error {
+	body, err := t.text.Render(e)
+	if err != nil {
+		return err
+	}
+	for _, to := range t.to {
+		params := &twilioApi.CreateMessageParams{}
+		params.SetTo(to)
+		params.SetFrom(t.from)
+		params.SetBody(body)
+		if _, err := t.client.Api.CreateMessage(params); err != nil {
+			return fmt.Errorf("unable to send sms to %s: %w", to, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the payload's HMAC-SHA256 signature as
+	// sha256=<hex>. The signed content is the timestamp header, a dot and the
+	// body, so receivers can reject old payloads.
+	SignatureHeader = "X-Rsvper-Signature"
+	// TimestampHeader is when the payload was signed, in unix seconds.
+	TimestampHeader = "X-Rsvper-Timestamp"
+
+	defaultWebhookAttempts = 3
+	webhookBackoff         = time.Second
+)
+
+// Format renders an event as a webhook request body.
+type Format func(e Event) ([]byte, error)
+
+// JSONFormat sends the event as is.
+func JSONFormat(e Event) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Webhook posts events as json to a url. Payloads are signed when it has a
+// secret, and failed deliveries are retried with backoff.
+type Webhook struct {
+	client   *http.Client
+	url      string
+	format   Format
+	secret   []byte
+	attempts int
+	backoff  time.Duration
+}
+
+// NewWebhook returns a webhook for url using format, JSONFormat when nil.
+func NewWebhook(url string, format Format) (*Webhook, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook url cannot be empty")
+	}
+	if format == nil {
+		format = JSONFormat
+	}
+
+	return &Webhook{
+		client:   &http.Client{Timeout: 10 * time.Second},
+		url:      url,
+		format:   format,
+		attempts: defaultWebhookAttempts,
+		backoff:  webhookBackoff,
+	}, nil
+}
+
+// SetSecret signs payloads with secret.
+func (w *Webhook) SetSecret(secret string) {
+	w.secret = []byte(secret)
+}
+
+// SetAttempts sets how many times a delivery is tried, at least once.
+func (w *Webhook) SetAttempts(attempts int) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	w.attempts = attempts
+}
+
+// SetClient replaces the http client deliveries are sent with.
+func (w *Webhook) SetClient(c *http.Client) {
+	w.client = c
+}
+
+func (w *Webhook) Notify(ctx context.Context, e Event) error {
+	body, err := w.format(e)
+	if err != nil {
+		return fmt.Errorf("unable to format webhook payload: %w", err)
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.attempts {
+			return fmt.Errorf("unable to deliver webhook after %d attempt(s): %w", attempt, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("unable to deliver webhook: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends the payload once. It reports whether a failure is worth
+// retrying: network errors, rate limits and server errors are, other
+// responses aren't.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(w.secret) > 0 {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, Sign(w.secret, ts, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for a payload sent at timestamp.
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
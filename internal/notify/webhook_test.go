@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// receiver records the webhook requests it gets and answers with the next
+// status, 200 once they run out.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header.Clone())
+	status := http.StatusOK
+	if len(r.statuses) > 0 {
+		status, r.statuses = r.statuses[0], r.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func newTestWebhook(t *testing.T, r *receiver, format Format) *Webhook {
+	t.Helper()
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	w, err := NewWebhook(srv.URL, format)
+	if err != nil {
+		t.Fatalf("unable to create webhook: %v", err)
+	}
+	w.SetClient(srv.Client())
+	w.backoff = time.Millisecond
+
+	return w
+}
+
+func testEvent(kind Kind) Event {
+	start := time.Date(2026, 10, 20, 17, 30, 0, 0, time.UTC)
+	return Event{
+		Kind:     kind,
+		Class:    cfa.Schedule{ID: 42, Title: "CrossFit\nSmall Group", Start: &start, URL: "/schedule/42/"},
+		Account:  "me@example.com",
+		Status:   "rsvped",
+		Attempts: 2,
+		Latency:  1500 * time.Millisecond,
+		Time:     start.Add(-120 * time.Hour),
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	r := &receiver{}
+	w := newTestWebhook(t, r, nil)
+	w.SetSecret("shh")
+
+	if err := w.Notify(context.Background(), testEvent(KindBooked)); err != nil {
+		t.Fatalf("unable to notify: %v", err)
+	}
+	if len(r.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(r.bodies))
+	}
+	ts := r.headers[0].Get(TimestampHeader)
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		t.Fatalf("got timestamp %q, want unix seconds", ts)
+	}
+	if got, want := r.headers[0].Get(SignatureHeader), Sign([]byte("shh"), ts, r.bodies[0]); got != want {
+		t.Errorf("got signature %q, want %q", got, want)
+	}
+	if got := r.headers[0].Get("Content-Type"); got != "application/json" {
+		t.Errorf("got content type %q, want application/json", got)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	r := &receiver{}
+	w := newTestWebhook(t, r, nil)
+
+	if err := w.Notify(context.Background(), testEvent(KindBooked)); err != nil {
+		t.Fatalf("unable to notify: %v", err)
+	}
+	if got := r.headers[0].Get(SignatureHeader); got != "" {
+		t.Errorf("got signature %q without a secret", got)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		fails    bool
+	}{
+		{name: "server error then ok", statuses: []int{500, 503}, requests: 3},
+		{name: "rate limited then ok", statuses: []int{429}, requests: 2},
+		{name: "server errors exhaust attempts", statuses: []int{500, 500, 500, 500}, requests: 3, fails: true},
+		{name: "client error", statuses: []int{400}, requests: 1, fails: true},
+		{name: "not found", statuses: []int{404}, requests: 1, fails: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &receiver{statuses: tt.statuses}
+			w := newTestWebhook(t, r, nil)
+			w.SetAttempts(3)
+
+			err := w.Notify(context.Background(), testEvent(KindBooked))
+			if tt.fails != (err != nil) {
+				t.Errorf("got error %v, want failure %t", err, tt.fails)
+			}
+			if len(r.bodies) != tt.requests {
+				t.Errorf("got %d requests, want %d", len(r.bodies), tt.requests)
+			}
+		})
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	r := &receiver{}
+	w := newTestWebhook(t, r, nil)
+
+	if err := w.Notify(context.Background(), testEvent(KindWaitlisted)); err != nil {
+		t.Fatalf("unable to notify: %v", err)
+	}
+	var got Event
+	if err := json.Unmarshal(r.bodies[0], &got); err != nil {
+		t.Fatalf("unable to decode payload: %v", err)
+	}
+	if got.Kind != KindWaitlisted || got.Class.ID != 42 || got.Account != "me@example.com" || got.Attempts != 2 {
+		t.Errorf("got %+v, want the waitlisted event for class 42", got)
+	}
+}
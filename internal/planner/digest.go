@@ -0,0 +1,265 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/deadletter"
+	"github.com/itsHabib/rsvper/internal/jobstore"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+)
+
+const digestTimeFormat = "Mon Jan 2 3:04PM"
+
+// Digest sums up an account's bookings over a period: the requested classes
+// starting within it that are booked or waitlisted, the triggers waiting to
+// fire and the bookings that failed during the last period.
+type Digest struct {
+	// Period is daily or weekly.
+	Period     string              `json:"period"`
+	From       time.Time           `json:"from"`
+	To         time.Time           `json:"to"`
+	Booked     []DigestClass       `json:"booked"`
+	Waitlisted []DigestClass       `json:"waitlisted"`
+	Pending    []scheduler.Trigger `json:"pending"`
+	Failures   []Failure           `json:"failures"`
+}
+
+// DigestClass is a class the account is booked or waitlisted for.
+type DigestClass struct {
+	Class cfa.Schedule `json:"class"`
+	// Position is the account's place on the wait list, 0 when it's not on
+	// it or the gym's site doesn't say.
+	Position int `json:"position,omitempty"`
+	// Promoted is set when a booked class came off the wait list since the
+	// account was told it was waitlisted.
+	Promoted bool `json:"promoted,omitempty"`
+}
+
+// Failure is a booking that failed.
+type Failure struct {
+	Class cfa.Schedule `json:"class"`
+	Error string       `json:"error,omitempty"`
+	Time  time.Time    `json:"time"`
+}
+
+// FailureSource lists the bookings that failed since a time.
+type FailureSource interface {
+	Failures(since time.Time) ([]Failure, error)
+}
+
+// JobStoreFailures reads failures from the rsvperd job store.
+type JobStoreFailures struct {
+	Store *jobstore.Store
+}
+
+func (f JobStoreFailures) Failures(since time.Time) ([]Failure, error) {
+	jobs, err := f.Store.Jobs()
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []Failure
+	for _, job := range jobs {
+		if (job.Status == jobstore.StatusFailed || job.Status == jobstore.StatusMissed) && !job.UpdatedAt.Before(since) {
+			failures = append(failures, Failure{Class: job.Trigger.Task.Schedule, Error: job.Error, Time: job.UpdatedAt})
+		}
+	}
+
+	return failures, nil
+}
+
+// DeadLetterFailures reads failures from the dead letter queue, leaving them
+// there for replay.
+type DeadLetterFailures struct {
+	Queue *deadletter.Queue
+}
+
+func (f DeadLetterFailures) Failures(since time.Time) ([]Failure, error) {
+	messages, err := f.Queue.Peek()
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []Failure
+	for _, msg := range messages {
+		if !msg.Sent.Before(since) {
+			failures = append(failures, Failure{Class: msg.Task.Schedule, Error: msg.Error, Time: msg.Sent})
+		}
+	}
+
+	return failures, nil
+}
+
+// Digest builds the digest for the configured period starting now. Booked
+// and waitlisted classes come from the requests file, checked against the
+// gym's site.
+func (j *Job) Digest(ctx context.Context) (*Digest, error) {
+	d, _, err := j.digest(ctx)
+
+	return d, err
+}
+
+// digest builds the digest and returns the account it's for.
+func (j *Job) digest(ctx context.Context) (*Digest, string, error) {
+	now := time.Now()
+	period := j.cfg.DigestPeriod()
+	d := Digest{Period: j.cfg.Digest, From: now, To: now.Add(period)}
+
+	sess, err := j.newSession()
+	if err != nil {
+		return nil, "", err
+	}
+	requests, queries, err := j.loadRequests(sess, j.cfg.Location(), func(start time.Time) bool {
+		return start.After(now) && !start.After(d.To)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	cfaService, schedulerService, account, err := j.connect()
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(requests)+len(queries) > 0 {
+		p, err := New(cfaService, schedulerService)
+		if err != nil {
+			return nil, "", err
+		}
+		classes, err := p.Classes(requests, queries)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, class := range classes {
+			if ctx.Err() != nil {
+				return nil, "", ctx.Err()
+			}
+			status, err := cfaService.CheckRSVP(class)
+			if err != nil {
+				return nil, "", fmt.Errorf("unable to check rsvp status for class %d: %w", class.ID, err)
+			}
+			switch status {
+			case cfa.RSVPED:
+				d.Booked = append(d.Booked, DigestClass{Class: class})
+			case cfa.WAITLISTED:
+				position, err := cfaService.WaitlistPosition(class)
+				if err != nil {
+					return nil, "", fmt.Errorf("unable to get wait list position for class %d: %w", class.ID, err)
+				}
+				d.Waitlisted = append(d.Waitlisted, DigestClass{Class: class, Position: position})
+			}
+		}
+	}
+
+	pending, err := schedulerService.PendingTriggers(now)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, t := range pending {
+		if t.Task.Account == "" || t.Task.Account == account {
+			d.Pending = append(d.Pending, t)
+		}
+	}
+
+	if j.failures != nil {
+		if d.Failures, err = j.failures.Failures(now.Add(-period)); err != nil {
+			return nil, "", fmt.Errorf("unable to list failures: %w", err)
+		}
+	}
+
+	return &d, account, nil
+}
+
+// SendDigest builds the digest and sends it to the notifiers as a summary.
+func (j *Job) SendDigest(ctx context.Context) (*Digest, error) {
+	d, account, err := j.digest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	j.notifyPromotions(ctx, d, account)
+
+	var text strings.Builder
+	d.WriteText(&text, j.cfg.Location())
+	event := notify.Event{Kind: notify.KindSummary, Message: text.String()}
+	if err := j.notifier.Notify(ctx, event); err != nil {
+		return d, fmt.Errorf("unable to send digest: %w", err)
+	}
+
+	return d, nil
+}
+
+// notifyPromotions sends a promoted event for each booked class the account
+// was told it was waitlisted for, and marks them in the digest. Without a
+// ledger shared with the bookings there's no telling which those are.
+func (j *Job) notifyPromotions(ctx context.Context, d *Digest, account string) {
+	if j.ledger == nil || len(d.Booked) == 0 {
+		return
+	}
+	booker, err := booking.NewBooker(j.secrets, j.notifier)
+	if err != nil {
+		fmt.Printf("unable to create booker: %v\n", err)
+		return
+	}
+	booker.SetLedger(j.ledger)
+	for i, c := range d.Booked {
+		task := scheduler.TaskRequest{Schedule: c.Class, Credentials: j.cfg.Credentials, Account: account}
+		d.Booked[i].Promoted = booker.NotifyPromotion(ctx, task)
+	}
+}
+
+// WriteText writes the digest as a plain text message with times in loc.
+func (d *Digest) WriteText(w io.Writer, loc *time.Location) {
+	at := func(t time.Time) string {
+		return t.In(loc).Format(digestTimeFormat)
+	}
+	start := func(class cfa.Schedule) string {
+		if class.Start == nil {
+			return "unknown time"
+		}
+		return at(*class.Start)
+	}
+
+	fmt.Fprintf(w, "rsvper %s digest, %s to %s\n", d.Period, at(d.From), at(d.To))
+	if len(d.Booked)+len(d.Waitlisted)+len(d.Pending)+len(d.Failures) == 0 {
+		fmt.Fprintln(w, "nothing booked, pending or failed")
+		return
+	}
+	if len(d.Booked) > 0 {
+		fmt.Fprintf(w, "\nbooked (%d):\n", len(d.Booked))
+		for _, c := range d.Booked {
+			promoted := ""
+			if c.Promoted {
+				promoted = ", promoted off the wait list"
+			}
+			fmt.Fprintf(w, "  %s, %s%s\n", notify.ClassTitle(c.Class), start(c.Class), promoted)
+		}
+	}
+	if len(d.Waitlisted) > 0 {
+		fmt.Fprintf(w, "\nwaitlisted (%d):\n", len(d.Waitlisted))
+		for _, c := range d.Waitlisted {
+			position := "position unknown"
+			if c.Position > 0 {
+				position = fmt.Sprintf("#%d", c.Position)
+			}
+			fmt.Fprintf(w, "  %s, %s, %s\n", notify.ClassTitle(c.Class), start(c.Class), position)
+		}
+	}
+	if len(d.Pending) > 0 {
+		fmt.Fprintf(w, "\npending triggers (%d):\n", len(d.Pending))
+		for _, t := range d.Pending {
+			fmt.Fprintf(w, "  %s, %s, books at %s\n", notify.ClassTitle(t.Task.Schedule), start(t.Task.Schedule), at(t.Time))
+		}
+	}
+	if len(d.Failures) > 0 {
+		fmt.Fprintf(w, "\nfailures (%d):\n", len(d.Failures))
+		for _, f := range d.Failures {
+			fmt.Fprintf(w, "  %s, %s: %s\n", notify.ClassTitle(f.Class), start(f.Class), f.Error)
+		}
+	}
+}
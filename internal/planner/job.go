@@ -0,0 +1,247 @@
+package planner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/itsHabib/rsvper/internal/blackout"
+	"github.com/itsHabib/rsvper/internal/booking"
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/notify"
+	"github.com/itsHabib/rsvper/internal/requestfile"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/secrets"
+	"github.com/itsHabib/rsvper/internal/shorthand"
+)
+
+// Job is the planning run that keeps triggers up to date without anyone
+// running the scheduler command. Each run reads the requests and blackouts
+// from their configured locations, logs in with the configured credentials,
+// plans triggers for the classes whose rsvp window opens within the plan
+// horizon, applies the plan and sends a summary. Runs are idempotent, so it
+// can run daily or more often.
+type Job struct {
+	cfg      *config.Config
+	backend  scheduler.TriggerBackend
+	secrets  secrets.Store
+	notifier notify.Notifier
+	failures FailureSource
+	ledger   booking.Ledger
+}
+
+func NewJob(cfg *config.Config, backend scheduler.TriggerBackend, store secrets.Store, notifier notify.Notifier) (*Job, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+	if backend == nil {
+		return nil, fmt.Errorf("trigger backend cannot be nil")
+	}
+	if store == nil {
+		return nil, fmt.Errorf("secret store cannot be nil")
+	}
+	if notifier == nil {
+		return nil, fmt.Errorf("notifier cannot be nil")
+	}
+	if err := cfg.RequireRequests(); err != nil {
+		return nil, err
+	}
+	if err := cfg.RequireCredentials(); err != nil {
+		return nil, err
+	}
+
+	return &Job{cfg: cfg, backend: backend, secrets: store, notifier: notifier}, nil
+}
+
+// SetFailures sets where the digest finds recent failed bookings.
+func (j *Job) SetFailures(source FailureSource) {
+	j.failures = source
+}
+
+// SetLedger sets the idempotency ledger shared with the bookings, which
+// bookings made by the run use and the digest reads promotions from.
+func (j *Job) SetLedger(l booking.Ledger) {
+	j.ledger = l
+}
+
+// Run plans and applies triggers and sends a summary of the outcome, or of
+// why the run failed. Runs with nothing to plan stay quiet.
+func (j *Job) Run(ctx context.Context) (*scheduler.Plan, error) {
+	plan, err := j.run(ctx, time.Now())
+	var text string
+	if plan != nil && len(plan.Items) > 0 {
+		var summary bytes.Buffer
+		plan.WriteSummary(&summary)
+		text = "rsvper plan:\n" + summary.String()
+	}
+	if err != nil {
+		text = fmt.Sprintf("rsvper planning failed: %v\n%s", err, text)
+	}
+	if text != "" {
+		event := notify.Event{Kind: notify.KindSummary, Message: text}
+		if notifyErr := j.notifier.Notify(ctx, event); notifyErr != nil {
+			fmt.Printf("unable to send plan summary: %v\n", notifyErr)
+		}
+	}
+
+	return plan, err
+}
+
+func (j *Job) run(ctx context.Context, now time.Time) (*scheduler.Plan, error) {
+	sess, err := j.newSession()
+	if err != nil {
+		return nil, err
+	}
+	loc := j.cfg.Location()
+
+	// plan the requests whose rsvp window opens within the horizon
+	requests, queries, err := j.loadRequests(sess, loc, func(start time.Time) bool {
+		return start.After(now) && start.Add(-cfa.MinimumRSVPTime).Before(now.Add(j.cfg.PlanHorizon))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(requests)+len(queries) == 0 {
+		fmt.Println("no requests within the plan horizon")
+		return &scheduler.Plan{}, nil
+	}
+	fmt.Printf("planning %d request(s) within %s\n", len(requests)+len(queries), j.cfg.PlanHorizon)
+
+	cfaService, schedulerService, _, err := j.connect()
+	if err != nil {
+		return nil, err
+	}
+	booker, err := booking.NewBooker(j.secrets, j.notifier)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create booker: %w", err)
+	}
+	if j.ledger != nil {
+		booker.SetLedger(j.ledger)
+	}
+	schedulerService.SetBooker(booker)
+	periods, err := j.loadBlackouts(sess)
+	if err != nil {
+		return nil, err
+	}
+	schedulerService.AddFilter(periods)
+
+	p, err := New(cfaService, schedulerService)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := p.Plan(requests, queries, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := schedulerService.Apply(ctx, plan); err != nil {
+		return plan, fmt.Errorf("unable to apply plan: %w", err)
+	}
+
+	return plan, nil
+}
+
+// newSession returns an aws session for reading the requests and blackouts.
+func (j *Job) newSession() (*session.Session, error) {
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(j.cfg.AWSRegion),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create new session: %w", err)
+	}
+
+	return sess, nil
+}
+
+// connect logs in with the configured credentials and returns a cfa service
+// and a scheduler service set up for the account, along with the username.
+func (j *Job) connect() (*cfa.Service, *scheduler.Service, string, error) {
+	c := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	cfaService, err := cfa.NewService(c)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to create cfa service: %w", err)
+	}
+	cfaService.SetLocation(j.cfg.Location())
+	creds, err := j.secrets.Credentials(j.cfg.Credentials)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to resolve credentials: %w", err)
+	}
+	if _, err := cfaService.Login(creds.Username, creds.Password); err != nil {
+		return nil, nil, "", fmt.Errorf("unable to login: %w", err)
+	}
+
+	schedulerService, err := scheduler.NewService(j.backend, cfaService)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("unable to create scheduler service: %w", err)
+	}
+	schedulerService.SetAccount(creds.Username)
+	schedulerService.SetCredentials(j.cfg.Credentials)
+	schedulerService.SetLeadTime(j.cfg.LeadTime)
+	for class, d := range j.cfg.ClassLeadTimes {
+		schedulerService.SetClassLeadTime(class, d)
+	}
+
+	return cfaService, schedulerService, creds.Username, nil
+}
+
+// loadRequests reads the requests file and keeps the requests for classes
+// whose start time passes keep.
+func (j *Job) loadRequests(sess *session.Session, loc *time.Location, keep func(start time.Time) bool) ([]cfa.ScheduleRequest, []shorthand.Query, error) {
+	data, err := readSource(sess, j.cfg.Requests)
+	if err != nil {
+		return nil, nil, err
+	}
+	format, err := requestfile.FormatFromPath(sourcePath(j.cfg.Requests))
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := requestfile.Parse(data, format, loc)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to load requests file: %w", err)
+	}
+
+	var requests []cfa.ScheduleRequest
+	for i := range file.Requests {
+		if keep(*file.Requests[i].StartTime) {
+			requests = append(requests, file.Requests[i])
+		}
+	}
+	var queries []shorthand.Query
+	for i := range file.Queries {
+		if keep(file.Queries[i].Start) {
+			queries = append(queries, file.Queries[i])
+		}
+	}
+
+	return requests, queries, nil
+}
+
+func (j *Job) loadBlackouts(sess *session.Session) (blackout.Periods, error) {
+	if j.cfg.Blackouts == "" {
+		return nil, nil
+	}
+	data, err := readSource(sess, j.cfg.Blackouts)
+	if errors.Is(err, errSourceNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	periods, err := blackout.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load blackouts: %w", err)
+	}
+
+	return periods, nil
+}
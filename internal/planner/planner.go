@@ -0,0 +1,163 @@
+// Package planner matches requests against the gym's schedule and plans their
+// triggers. It's shared by the scheduler command and the planning job that
+// runs on its own schedule.
+package planner
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/config"
+	"github.com/itsHabib/rsvper/internal/scheduler"
+	"github.com/itsHabib/rsvper/internal/shorthand"
+)
+
+type Planner struct {
+	cfa       *cfa.Service
+	scheduler *scheduler.Service
+	log       io.Writer
+}
+
+// New returns a planner. The cfa service must already be logged in.
+func New(cfaService *cfa.Service, schedulerService *scheduler.Service) (*Planner, error) {
+	if cfaService == nil {
+		return nil, fmt.Errorf("cfa service cannot be nil")
+	}
+	if schedulerService == nil {
+		return nil, fmt.Errorf("scheduler service cannot be nil")
+	}
+
+	return &Planner{cfa: cfaService, scheduler: schedulerService, log: os.Stdout}, nil
+}
+
+// SetLog sets where progress is logged, stdout by default.
+func (p *Planner) SetLog(w io.Writer) {
+	p.log = w
+}
+
+// Plan fetches the schedule covering the requests, resolves shorthand
+// queries against it and plans a trigger for each request. With prune, the
+// requests are the desired state and pending triggers that aren't requested
+// anymore are planned for deletion. Nothing is changed until the plan is
+// applied.
+func (p *Planner) Plan(requests []cfa.ScheduleRequest, queries []shorthand.Query, prune bool) (*scheduler.Plan, error) {
+	schedule, requests, err := p.resolve(requests, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	// match requests against the schedule to plan triggers
+	plan, err := p.scheduler.Plan(schedule, requests)
+	if err != nil {
+		return nil, fmt.Errorf("unable to plan requests: %w", err)
+	}
+	if prune {
+		if err := p.scheduler.PlanPrune(plan, time.Now()); err != nil {
+			return nil, fmt.Errorf("unable to plan deletions: %w", err)
+		}
+	}
+
+	return plan, nil
+}
+
+// Classes returns the scheduled classes the requests match, in start order.
+func (p *Planner) Classes(requests []cfa.ScheduleRequest, queries []shorthand.Query) ([]cfa.Schedule, error) {
+	schedule, requests, err := p.resolve(requests, queries)
+	if err != nil {
+		return nil, err
+	}
+
+	var classes []cfa.Schedule
+	seen := make(map[int]bool)
+	for i := range requests {
+		for j := range schedule {
+			if !seen[schedule[j].ID] && scheduler.MatchesRequest(schedule[j], requests[i]) {
+				seen[schedule[j].ID] = true
+				classes = append(classes, schedule[j])
+			}
+		}
+	}
+
+	return classes, nil
+}
+
+// resolve fetches the schedule covering the requests and resolves shorthand
+// queries against it. It returns the schedule and every request, sorted by
+// start time.
+func (p *Planner) resolve(requests []cfa.ScheduleRequest, queries []shorthand.Query) ([]cfa.Schedule, []cfa.ScheduleRequest, error) {
+	var schedule []cfa.Schedule
+	if len(requests)+len(queries) > 0 {
+		// form get schedule params
+		first, last := requestRange(requests, queries)
+		params := cfa.ScheduleParams{
+			Name:      cfa.InHouseSessions,
+			StartDate: fmt.Sprintf("%d-%02d-%02d", first.Year(), first.Month(), first.Day()),
+			EndDate:   fmt.Sprintf("%d-%02d-%02d", last.Year(), last.Month(), last.Day()),
+		}
+		// get schedule
+		var err error
+		if schedule, err = p.cfa.GetSchedule(params); err != nil {
+			return nil, nil, fmt.Errorf("unable to get schedule: %w", err)
+		}
+	}
+
+	// resolve shorthand requests now that we know which classes exist
+	for i := range queries {
+		req, err := shorthand.Resolve(queries[i], schedule)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to resolve %q: %w", queries[i].Text, err)
+		}
+		fmt.Fprintf(p.log, "resolved %q to %s at %s\n", queries[i].Text, req.ClassName, req.StartTime.Format(time.RFC3339))
+		requests = append(requests, req)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].StartTime.Before(*requests[j].StartTime)
+	})
+
+	return schedule, requests, nil
+}
+
+// NewEventBridgeBackend creates the EventBridge trigger backend described by
+// the config. Its schedule group is created when a plan first creates a
+// trigger in it, so read only commands don't need write access.
+func NewEventBridgeBackend(sess *session.Session, cfg *config.Config) (*scheduler.EventBridgeBackend, error) {
+	if err := cfg.RequireTargets(); err != nil {
+		return nil, err
+	}
+	backend, err := scheduler.NewEventBridgeBackend(sess, scheduler.EventBridgeConfig{
+		Timezone:      cfg.Timezone,
+		LambdaARN:     cfg.LambdaARN,
+		RoleARN:       cfg.RoleARN,
+		Group:         cfg.ScheduleGroup,
+		Tags:          cfg.Tags,
+		MaxRetries:    cfg.Retry.MaximumAttempts,
+		MaxEventAge:   cfg.Retry.MaximumEventAge,
+		DeadLetterARN: cfg.DeadLetterARN,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create eventbridge backend: %w", err)
+	}
+	return backend, nil
+}
+
+// requestRange returns the earliest and latest start times of the requests.
+func requestRange(requests []cfa.ScheduleRequest, queries []shorthand.Query) (time.Time, time.Time) {
+	var starts []time.Time
+	for i := range requests {
+		starts = append(starts, *requests[i].StartTime)
+	}
+	for i := range queries {
+		starts = append(starts, queries[i].Start)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i].Before(starts[j])
+	})
+
+	return starts[0], starts[len(starts)-1]
+}
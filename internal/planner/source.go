@@ -0,0 +1,66 @@
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// errSourceNotFound is returned when a source file doesn't exist.
+var errSourceNotFound = errors.New("source not found")
+
+// readSource reads a file from a local path or an s3://bucket/key url.
+func readSource(sess *session.Session, location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		data, err := os.ReadFile(location)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", errSourceNotFound, location)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %w", location, err)
+		}
+		return data, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 url %q: %w", location, err)
+	}
+	out, err := s3.New(sess).GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(u.Host),
+		Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, fmt.Errorf("%w: %s", errSourceNotFound, location)
+		}
+		return nil, fmt.Errorf("unable to get %s: %w", location, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", location, err)
+	}
+
+	return data, nil
+}
+
+// sourcePath returns the path part of a location, used to tell the file's
+// format from its extension.
+func sourcePath(location string) string {
+	if u, err := url.Parse(location); err == nil && u.Scheme == "s3" {
+		return u.Path
+	}
+
+	return location
+}
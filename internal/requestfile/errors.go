@@ -0,0 +1,38 @@
+package requestfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a single problem found in a requests file.
+type Error struct {
+	Line  int
+	Field string
+	Msg   string
+}
+
+func (e Error) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Field != "" {
+		fmt.Fprintf(&b, "%s: ", e.Field)
+	}
+	b.WriteString(e.Msg)
+
+	return b.String()
+}
+
+// Errors is every problem found while validating a requests file.
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+
+	return fmt.Sprintf("invalid requests file:\n%s", strings.Join(msgs, "\n"))
+}
@@ -0,0 +1,127 @@
+package requestfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+func parseJSON(data []byte) (*document, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, jsonError(data, dec, err)
+	}
+
+	switch tok {
+	case json.Delim('['):
+		requests, err := readJSONEntries(data, dec)
+		if err != nil {
+			return nil, err
+		}
+		return &document{legacy: true, requests: requests}, nil
+	case json.Delim('{'):
+		doc := document{fields: make(map[string]field)}
+		for dec.More() {
+			key, line, err := readJSONKey(data, dec)
+			if err != nil {
+				return nil, err
+			}
+			if key == requestsField {
+				tok, err := dec.Token()
+				if err != nil {
+					return nil, jsonError(data, dec, err)
+				}
+				if tok != json.Delim('[') {
+					return nil, Errors{{Line: line, Field: requestsField, Msg: "must be a list"}}
+				}
+				if doc.requests, err = readJSONEntries(data, dec); err != nil {
+					return nil, err
+				}
+				doc.fields[key] = field{line: line}
+				continue
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, jsonError(data, dec, err)
+			}
+			doc.fields[key] = field{value: v, line: line}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+		return &doc, nil
+	default:
+		return nil, Errors{{Line: lineAt(data, dec.InputOffset()), Msg: "expected an object with version and requests"}}
+	}
+}
+
+// readJSONEntries reads request objects until the end of the current list.
+// The opening bracket must already have been consumed.
+func readJSONEntries(data []byte, dec *json.Decoder) ([]entry, error) {
+	var entries []entry
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+		e := entry{line: lineAt(data, dec.InputOffset()), fields: make(map[string]field)}
+		if tok != json.Delim('{') {
+			return nil, Errors{{Line: e.line, Field: fmt.Sprintf("%s[%d]", requestsField, len(entries)), Msg: "must be an object"}}
+		}
+		for dec.More() {
+			key, line, err := readJSONKey(data, dec)
+			if err != nil {
+				return nil, err
+			}
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, jsonError(data, dec, err)
+			}
+			e.fields[key] = field{value: v, line: line}
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, jsonError(data, dec, err)
+		}
+		entries = append(entries, e)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, jsonError(data, dec, err)
+	}
+
+	return entries, nil
+}
+
+func readJSONKey(data []byte, dec *json.Decoder) (string, int, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", 0, jsonError(data, dec, err)
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", 0, Errors{{Line: lineAt(data, dec.InputOffset()), Msg: "expected an object key"}}
+	}
+
+	return key, lineAt(data, dec.InputOffset()), nil
+}
+
+// jsonError converts a decoder error into an Errors value pointing at the
+// line the error occurred on.
+func jsonError(data []byte, dec *json.Decoder, err error) error {
+	offset := dec.InputOffset()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	}
+
+	return Errors{{Line: lineAt(data, offset), Msg: err.Error()}}
+}
+
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
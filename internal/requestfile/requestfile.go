@@ -0,0 +1,91 @@
+package requestfile
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/shorthand"
+)
+
+// Version is the current version of the requests file format.
+const Version = 1
+
+// Schema is the JSON schema for version 1 requests files. YAML and TOML files
+// follow the same structure.
+//
+//go:embed requests.v1.schema.json
+var Schema []byte
+
+type Format string
+
+const (
+	JSON Format = "json"
+	YAML Format = "yaml"
+	TOML Format = "toml"
+)
+
+// File is a validated requests file. Requests written in shorthand are kept
+// in Queries until they are resolved against the schedule.
+type File struct {
+	Version  int                   `json:"version"`
+	Requests []cfa.ScheduleRequest `json:"requests"`
+	Queries  []shorthand.Query     `json:"queries,omitempty"`
+}
+
+// FormatFromPath returns the file format based on the file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return JSON, nil
+	case ".yaml", ".yml":
+		return YAML, nil
+	case ".toml":
+		return TOML, nil
+	default:
+		return "", fmt.Errorf("unsupported requests file extension: %q", filepath.Ext(path))
+	}
+}
+
+// Load reads, parses and validates the requests file at path. Shorthand
+// requests are resolved in loc, the gym's timezone. If the file is invalid the
+// returned error is an Errors value listing every problem found.
+func Load(path string, loc *time.Location) (*File, error) {
+	format, err := FormatFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read requests file: %w", err)
+	}
+
+	return Parse(data, format, loc)
+}
+
+// Parse parses and validates requests file contents in the given format.
+func Parse(data []byte, format Format, loc *time.Location) (*File, error) {
+	var (
+		doc *document
+		err error
+	)
+	switch format {
+	case JSON:
+		doc, err = parseJSON(data)
+	case YAML:
+		doc, err = parseYAML(data)
+	case TOML:
+		doc, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("unsupported requests file format: %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.validate(time.Now(), loc)
+}
@@ -0,0 +1,408 @@
+package requestfile
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// request is a valid request file entry's class and start in RFC3339.
+type request struct {
+	class string
+	start string
+}
+
+func TestParse(t *testing.T) {
+	loc, err := time.LoadLocation(cfa.Timezone)
+	if err != nil {
+		t.Fatalf("unable to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		format   Format
+		data     string
+		requests []request
+		queries  []request
+	}{
+		{
+			name:   "json",
+			format: JSON,
+			data: `{
+  "version": 1,
+  "requests": [
+    {"className": "Small Group", "startTime": "2026-10-20T17:30:00-05:00"},
+    {"className": "Range & Resilience", "startTime": "2026-10-21T06:00:00-05:00", "leadTime": "3m"}
+  ]
+}`,
+			requests: []request{{"Small Group", "2026-10-20T17:30:00-05:00"}, {"Range & Resilience", "2026-10-21T06:00:00-05:00"}},
+		},
+		{
+			name:   "yaml",
+			format: YAML,
+			data: `version: 1
+requests:
+  - className: Small Group
+    startTime: 2026-10-20T17:30:00-05:00
+  - className: Range & Resilience
+    startTime: "2026-10-21T06:00:00-05:00"
+    leadTime: 3m
+`,
+			requests: []request{{"Small Group", "2026-10-20T17:30:00-05:00"}, {"Range & Resilience", "2026-10-21T06:00:00-05:00"}},
+		},
+		{
+			name:   "toml",
+			format: TOML,
+			data: `version = 1
+
+[[requests]]
+className = "Small Group"
+startTime = 2026-10-20T17:30:00-05:00
+
+[[requests]]
+className = "Range & Resilience"
+startTime = "2026-10-21T06:00:00-05:00"
+leadTime = "3m"
+`,
+			requests: []request{{"Small Group", "2026-10-20T17:30:00-05:00"}, {"Range & Resilience", "2026-10-21T06:00:00-05:00"}},
+		},
+		{
+			name:     "legacy json array",
+			format:   JSON,
+			data:     `[{"className": "Small Group", "startTime": "2026-10-20T17:30:00-05:00"}]`,
+			requests: []request{{"Small Group", "2026-10-20T17:30:00-05:00"}},
+		},
+		{
+			name:   "json shorthand",
+			format: JSON,
+			data: `{"version": 1, "requests": [
+  {"when": "2026-10-20 5:30pm small group", "leadTime": "3m"},
+  {"className": "Small Group", "startTime": "2026-10-21T17:30:00-05:00"}
+]}`,
+			requests: []request{{"Small Group", "2026-10-21T17:30:00-05:00"}},
+			queries:  []request{{"small group", "2026-10-20T17:30:00-05:00"}},
+		},
+		{
+			name:   "yaml shorthand",
+			format: YAML,
+			data: `version: 1
+requests:
+  - when: 2026-10-20 17:30 range
+`,
+			queries: []request{{"range", "2026-10-20T17:30:00-05:00"}},
+		},
+		{
+			name:   "toml shorthand",
+			format: TOML,
+			data: `version = 1
+
+[[requests]]
+when = "2026-10-20 6am small group"
+`,
+			queries: []request{{"small group", "2026-10-20T06:00:00-05:00"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := Parse([]byte(tt.data), tt.format, loc)
+			if err != nil {
+				t.Fatalf("unable to parse: %v", err)
+			}
+			if file.Version != Version {
+				t.Errorf("got version %d, want %d", file.Version, Version)
+			}
+
+			var requests []request
+			for _, r := range file.Requests {
+				requests = append(requests, request{r.ClassName, r.StartTime.Format(time.RFC3339)})
+			}
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("got requests %v, want %v", requests, tt.requests)
+			}
+			var queries []request
+			for _, q := range file.Queries {
+				queries = append(queries, request{q.Class, q.Start.Format(time.RFC3339)})
+			}
+			if !reflect.DeepEqual(queries, tt.queries) {
+				t.Errorf("got queries %v, want %v", queries, tt.queries)
+			}
+		})
+	}
+}
+
+func TestParseLeadTime(t *testing.T) {
+	file, err := Parse([]byte(`{"version": 1, "requests": [
+  {"className": "Small Group", "startTime": "2026-10-20T17:30:00-05:00", "leadTime": "3m"},
+  {"when": "2026-10-21 17:30 small group", "leadTime": "4m"}
+]}`), JSON, time.UTC)
+	if err != nil {
+		t.Fatalf("unable to parse: %v", err)
+	}
+	if got := file.Requests[0].LeadTime; got != 3*time.Minute {
+		t.Errorf("got request lead time %s, want 3m", got)
+	}
+	if got := file.Queries[0].LeadTime; got != 4*time.Minute {
+		t.Errorf("got query lead time %s, want 4m", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		want   Errors
+	}{
+		{
+			name:   "json unknown fields",
+			format: JSON,
+			data: `{
+  "version": 1,
+  "extra": true,
+  "requests": [
+    {
+      "className": "Small Group",
+      "startTime": "2026-10-20T17:30:00-05:00",
+      "coach": "Sam"
+    }
+  ]
+}`,
+			want: Errors{
+				{Line: 3, Field: "extra", Msg: "unknown field"},
+				{Line: 8, Field: "requests[0].coach", Msg: "unknown field"},
+			},
+		},
+		{
+			name:   "yaml unknown fields",
+			format: YAML,
+			data: `version: 1
+extra: true
+requests:
+  - className: Small Group
+    startTime: 2026-10-20T17:30:00-05:00
+    coach: Sam
+`,
+			want: Errors{
+				{Line: 2, Field: "extra", Msg: "unknown field"},
+				{Line: 6, Field: "requests[0].coach", Msg: "unknown field"},
+			},
+		},
+		{
+			name:   "toml unknown fields",
+			format: TOML,
+			data: `version = 1
+extra = true
+
+[[requests]]
+className = "Small Group"
+startTime = "2026-10-20T17:30:00-05:00"
+coach = "Sam"
+`,
+			want: Errors{
+				{Line: 2, Field: "extra", Msg: "unknown field"},
+				{Line: 7, Field: "requests[0].coach", Msg: "unknown field"},
+			},
+		},
+		{
+			name:   "json unsupported version",
+			format: JSON,
+			data: `{
+  "version": 2,
+  "requests": []
+}`,
+			want: Errors{{Line: 2, Field: "version", Msg: "unsupported version 2, expected 1"}},
+		},
+		{
+			name:   "yaml unsupported version",
+			format: YAML,
+			data:   "requests: []\nversion: \"1\"\n",
+			want:   Errors{{Line: 2, Field: "version", Msg: "unsupported version 1, expected 1"}},
+		},
+		{
+			name:   "toml unsupported version",
+			format: TOML,
+			data:   "version = 1.5\n\n[[requests]]\nwhen = \"2026-10-20 17:30 small group\"\n",
+			want:   Errors{{Line: 1, Field: "version", Msg: "unsupported version 1.5, expected 1"}},
+		},
+		{
+			name:   "missing version and requests",
+			format: YAML,
+			data:   "{}\n",
+			want: Errors{
+				{Line: 1, Field: "version", Msg: "missing required field"},
+				{Line: 1, Field: "requests", Msg: "missing required field"},
+			},
+		},
+		{
+			name:   "invalid requests",
+			format: JSON,
+			data: `{"version": 1, "requests": [
+  {"className": "Yoga", "startTime": "2026-10-20 17:30"},
+  {"startTime": "2026-10-20T17:30:00-05:00", "leadTime": "1h"},
+  {"className": "Small Group", "startTime": "2026-10-20T17:30:00-05:00"},
+  {"className": "Small Group", "startTime": "2026-10-20T22:30:00Z"}
+]}`,
+			want: Errors{
+				{Line: 2, Field: "requests[0].className", Msg: `unknown class "Yoga", expected one of: CrossFit Small Group Session, Range & Resilience`},
+				{Line: 2, Field: "requests[0].startTime", Msg: `invalid time "2026-10-20 17:30", expected RFC3339 like 2006-01-02T15:04:05-07:00`},
+				{Line: 3, Field: "requests[1].className", Msg: "missing required field"},
+				{Line: 3, Field: "requests[1].leadTime", Msg: "lead time 1h0m0s must be more than 0 and at most 9m0s"},
+				{Line: 5, Field: "requests[3]", Msg: "duplicate of request on line 4"},
+			},
+		},
+		{
+			name:   "json when with class name",
+			format: JSON,
+			data: `{"version": 1, "requests": [
+  {"when": "2026-10-20 17:30 small group", "className": "Small Group"}
+]}`,
+			want: Errors{{Line: 2, Field: "requests[0].className", Msg: "cannot be used together with when"}},
+		},
+		{
+			name:   "yaml when errors",
+			format: YAML,
+			data: `version: 1
+requests:
+  - when: someday 17:30 small group
+  - when: 2026-10-20 17:30 yoga
+  - when: 2026-10-20 17:30
+  - when: 5
+`,
+			want: Errors{
+				{Line: 3, Field: "requests[0].when", Msg: `unrecognized day "someday", expected a weekday, today, tomorrow, next <weekday> or yyyy-mm-dd`},
+				{Line: 4, Field: "requests[1].when", Msg: `unknown class "yoga", expected one of: CrossFit Small Group Session, Range & Resilience`},
+				{Line: 5, Field: "requests[2].when", Msg: `missing class name in "2026-10-20 17:30"`},
+				{Line: 6, Field: "requests[3].when", Msg: "must be a string"},
+			},
+		},
+		{
+			name:   "toml duplicate when",
+			format: TOML,
+			data: `version = 1
+
+[[requests]]
+when = "2026-10-20 17:30 small group"
+
+[[requests]]
+when = "2026-10-20 5:30pm Small Group"
+`,
+			want: Errors{{Line: 6, Field: "requests[1]", Msg: "duplicate of request on line 3"}},
+		},
+		{
+			name:   "json requests not a list",
+			format: JSON,
+			data:   "{\n  \"version\": 1,\n  \"requests\": {}\n}",
+			want:   Errors{{Line: 3, Field: "requests", Msg: "must be a list"}},
+		},
+		{
+			name:   "toml requests not tables",
+			format: TOML,
+			data:   "version = 1\nrequests = []\n",
+			want:   Errors{{Line: 2, Field: "requests", Msg: "must be a list of [[requests]] tables"}},
+		},
+		{
+			name:   "yaml request not a mapping",
+			format: YAML,
+			data:   "version: 1\nrequests:\n  - small group\n",
+			want:   Errors{{Line: 3, Field: "requests[0]", Msg: "must be a mapping"}},
+		},
+		{
+			name:   "json top level not an object",
+			format: JSON,
+			data:   `"requests"`,
+			want:   Errors{{Line: 1, Msg: "expected an object with version and requests"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format, time.UTC)
+			var got Errors
+			if !errors.As(err, &got) {
+				t.Fatalf("got error %v, want Errors", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors\n%v\nwant\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		data   string
+		line   int
+		msg    string
+	}{
+		{
+			name:   "json",
+			format: JSON,
+			data:   "{\n  \"version\": 1,\n  \"requests\": [\n    {\"className\": }\n  ]\n}",
+			line:   4,
+			msg:    "invalid character '}' looking for beginning of value",
+		},
+		{name: "json unterminated", format: JSON, data: "{\n  \"version\": 1,\n", line: 3, msg: "unexpected end of JSON input"},
+		{
+			name:   "toml",
+			format: TOML,
+			data:   "version = 1\n\n[[requests]]\nclassName = \"Small Group\"\nclassName = \"Range\"\n",
+			line:   5,
+			msg:    "Key 'requests.className' has already been defined.",
+		},
+		// the lexer points at the line after a missing value
+		{name: "toml missing value", format: TOML, data: "version = 1\n\n[[requests]]\nclassName = \n", line: 5, msg: "expected value but found '\\n' instead"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format, time.UTC)
+			var got Errors
+			if !errors.As(err, &got) || len(got) != 1 {
+				t.Fatalf("got error %v, want a single error", err)
+			}
+			if got[0].Line != tt.line || got[0].Msg != tt.msg {
+				t.Errorf("got %q on line %d, want %q on line %d", got[0].Msg, got[0].Line, tt.msg, tt.line)
+			}
+		})
+	}
+
+	// yaml reports the line in its own message
+	_, err := Parse([]byte("version: 1\nrequests:\n  - className: [\n"), YAML, time.UTC)
+	if err == nil || !strings.Contains(err.Error(), "yaml: line 3:") {
+		t.Errorf("got %v, want the yaml error's line", err)
+	}
+}
+
+func TestErrorsMessage(t *testing.T) {
+	err := Errors{
+		{Line: 3, Field: "extra", Msg: "unknown field"},
+		{Field: "version", Msg: "missing required field"},
+		{Msg: "file is empty"},
+	}
+	want := "invalid requests file:\nline 3: extra: unknown field\nversion: missing required field\nfile is empty"
+	if got := err.Error(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	tests := map[string]Format{
+		"requests.json": JSON,
+		"requests.YAML": YAML,
+		"requests.yml":  YAML,
+		"requests.toml": TOML,
+	}
+	for path, want := range tests {
+		if got, err := FormatFromPath(path); err != nil || got != want {
+			t.Errorf("got %q, %v for %s, want %q", got, err, path, want)
+		}
+	}
+	if _, err := FormatFromPath("requests.txt"); err == nil {
+		t.Error("got no error for a .txt file")
+	}
+}
@@ -0,0 +1,120 @@
+package requestfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+var tomlKeyRegex = regexp.MustCompile(`^\s*(?:"([^"]+)"|([A-Za-z0-9_-]+))\s*=`)
+
+func parseTOML(data []byte) (*document, error) {
+	var raw map[string]interface{}
+	if _, err := toml.Decode(string(data), &raw); err != nil {
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			msg := parseErr.Message
+			if msg == "" {
+				// lexer errors only have their message in Error(), after
+				// the line and last key
+				_, msg, _ = strings.Cut(strings.TrimPrefix(parseErr.Error(), "toml: "), ": ")
+			}
+			return nil, Errors{{Line: parseErr.Position.Line, Msg: msg}}
+		}
+		return nil, Errors{{Msg: err.Error()}}
+	}
+
+	// the toml decoder doesn't keep track of positions, so find the lines
+	// of keys and request tables by scanning the file.
+	lines := scanTOMLLines(data)
+
+	doc := document{fields: make(map[string]field)}
+	for key, value := range raw {
+		line := lines.top[key]
+		if key != requestsField {
+			doc.fields[key] = field{value: value, line: line}
+			continue
+		}
+		doc.fields[key] = field{line: line}
+		tables, ok := value.([]map[string]interface{})
+		if !ok {
+			return nil, Errors{{Line: line, Field: requestsField, Msg: "must be a list of [[requests]] tables"}}
+		}
+		for i := range tables {
+			e := entry{line: line, fields: make(map[string]field)}
+			var keyLines map[string]int
+			if i < len(lines.requests) {
+				e.line = lines.requests[i].line
+				keyLines = lines.requests[i].keys
+			}
+			for k, v := range tables[i] {
+				kl, ok := keyLines[k]
+				if !ok {
+					kl = e.line
+				}
+				e.fields[k] = field{value: v, line: kl}
+			}
+			doc.requests = append(doc.requests, e)
+		}
+	}
+
+	return &doc, nil
+}
+
+type tomlTable struct {
+	line int
+	keys map[string]int
+}
+
+type tomlLines struct {
+	top      map[string]int
+	requests []tomlTable
+}
+
+func scanTOMLLines(data []byte) tomlLines {
+	lines := tomlLines{top: make(map[string]int)}
+	// current is nil while scanning top level keys or tables other than
+	// [[requests]]
+	var (
+		current *tomlTable
+		inTop   = true
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(text, "[["+requestsField+"]]"):
+			if _, ok := lines.top[requestsField]; !ok {
+				lines.top[requestsField] = n
+			}
+			lines.requests = append(lines.requests, tomlTable{line: n, keys: make(map[string]int)})
+			current = &lines.requests[len(lines.requests)-1]
+			inTop = false
+		case strings.HasPrefix(text, "["):
+			name := strings.Trim(text, "[] ")
+			if _, ok := lines.top[name]; !ok {
+				lines.top[name] = n
+			}
+			current = nil
+			inTop = false
+		default:
+			m := tomlKeyRegex.FindStringSubmatch(text)
+			if m == nil {
+				continue
+			}
+			key := m[1] + m[2]
+			if inTop {
+				lines.top[key] = n
+			} else if current != nil {
+				current.keys[key] = n
+			}
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,269 @@
+package requestfile
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+	"github.com/itsHabib/rsvper/internal/shorthand"
+)
+
+const (
+	versionField   = "version"
+	requestsField  = "requests"
+	classNameField = "className"
+	startTimeField = "startTime"
+	whenField      = "when"
+	leadTimeField  = "leadTime"
+)
+
+// field is a decoded value along with the line it was found on.
+type field struct {
+	value interface{}
+	line  int
+}
+
+// entry is a single undecoded request from the requests list.
+type entry struct {
+	line   int
+	fields map[string]field
+}
+
+// document is the format independent representation of a requests file that
+// keeps track of line numbers so validation errors can point at them.
+type document struct {
+	// legacy is set for files that are a bare list of requests without a
+	// version.
+	legacy   bool
+	fields   map[string]field
+	requests []entry
+}
+
+func (d *document) validate(now time.Time, loc *time.Location) (*File, error) {
+	var errs Errors
+	file := File{Version: Version}
+
+	if !d.legacy {
+		for _, name := range sortedKeys(d.fields) {
+			if name != versionField && name != requestsField {
+				errs = append(errs, Error{Line: d.fields[name].line, Field: name, Msg: "unknown field"})
+			}
+		}
+		if f, ok := d.fields[versionField]; !ok {
+			errs = append(errs, Error{Line: 1, Field: versionField, Msg: "missing required field"})
+		} else if v, ok := asInt(f.value); !ok || v != Version {
+			errs = append(errs, Error{Line: f.line, Field: versionField, Msg: fmt.Sprintf("unsupported version %v, expected %d", f.value, Version)})
+		}
+		if _, ok := d.fields[requestsField]; !ok {
+			errs = append(errs, Error{Line: 1, Field: requestsField, Msg: "missing required field"})
+		}
+	}
+
+	seen := make(map[string]int)
+	for i := range d.requests {
+		var (
+			key     string
+			query   *shorthand.Query
+			req     cfa.ScheduleRequest
+			reqErrs Errors
+		)
+		if _, ok := d.requests[i].fields[whenField]; ok {
+			query, reqErrs = d.requests[i].validateShorthand(i, now, loc)
+		} else {
+			req, reqErrs = d.requests[i].validate(i)
+		}
+		errs = append(errs, reqErrs...)
+		if len(reqErrs) > 0 {
+			continue
+		}
+		if query != nil {
+			key = strings.ToLower(query.Class) + "|" + query.Start.UTC().Format(time.RFC3339)
+		} else {
+			key = req.ClassName + "|" + req.StartTime.UTC().Format(time.RFC3339)
+		}
+		if line, ok := seen[key]; ok {
+			errs = append(errs, Error{
+				Line:  d.requests[i].line,
+				Field: fmt.Sprintf("%s[%d]", requestsField, i),
+				Msg:   fmt.Sprintf("duplicate of request on line %d", line),
+			})
+			continue
+		}
+		seen[key] = d.requests[i].line
+		if query != nil {
+			file.Queries = append(file.Queries, *query)
+			continue
+		}
+		file.Requests = append(file.Requests, req)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &file, nil
+}
+
+func (e *entry) validate(idx int) (cfa.ScheduleRequest, Errors) {
+	var (
+		req  cfa.ScheduleRequest
+		errs Errors
+	)
+	path := func(name string) string {
+		return fmt.Sprintf("%s[%d].%s", requestsField, idx, name)
+	}
+
+	for _, name := range sortedKeys(e.fields) {
+		if name != classNameField && name != startTimeField && name != leadTimeField {
+			errs = append(errs, Error{Line: e.fields[name].line, Field: path(name), Msg: "unknown field"})
+		}
+	}
+
+	if f, ok := e.fields[classNameField]; !ok {
+		errs = append(errs, Error{Line: e.line, Field: path(classNameField), Msg: "missing required field"})
+	} else if name, ok := f.value.(string); !ok || strings.TrimSpace(name) == "" {
+		errs = append(errs, Error{Line: f.line, Field: path(classNameField), Msg: "must be a non-empty string"})
+	} else if !knownClass(name) {
+		errs = append(errs, Error{Line: f.line, Field: path(classNameField), Msg: fmt.Sprintf("unknown class %q, expected one of: %s", name, strings.Join(cfa.Classes, ", "))})
+	} else {
+		req.ClassName = name
+	}
+
+	if f, ok := e.fields[startTimeField]; !ok {
+		errs = append(errs, Error{Line: e.line, Field: path(startTimeField), Msg: "missing required field"})
+	} else if t, err := asTime(f.value); err != nil {
+		errs = append(errs, Error{Line: f.line, Field: path(startTimeField), Msg: err.Error()})
+	} else {
+		req.StartTime = &t
+	}
+
+	if f, ok := e.fields[leadTimeField]; ok {
+		d, err := asLeadTime(f.value)
+		if err != nil {
+			errs = append(errs, Error{Line: f.line, Field: path(leadTimeField), Msg: err.Error()})
+		}
+		req.LeadTime = d
+	}
+
+	return req, errs
+}
+
+// validateShorthand validates a request written as a shorthand "when"
+// string, which replaces the className and startTime fields.
+func (e *entry) validateShorthand(idx int, now time.Time, loc *time.Location) (*shorthand.Query, Errors) {
+	var errs Errors
+	path := func(name string) string {
+		return fmt.Sprintf("%s[%d].%s", requestsField, idx, name)
+	}
+
+	for _, name := range sortedKeys(e.fields) {
+		if name != whenField && name != leadTimeField {
+			errs = append(errs, Error{Line: e.fields[name].line, Field: path(name), Msg: fmt.Sprintf("cannot be used together with %s", whenField)})
+		}
+	}
+
+	f := e.fields[whenField]
+	text, ok := f.value.(string)
+	if !ok {
+		return nil, append(errs, Error{Line: f.line, Field: path(whenField), Msg: "must be a string"})
+	}
+	query, err := shorthand.Parse(text, now, loc)
+	if err != nil {
+		return nil, append(errs, Error{Line: f.line, Field: path(whenField), Msg: err.Error()})
+	}
+	if !knownShorthandClass(query.Class) {
+		errs = append(errs, Error{Line: f.line, Field: path(whenField), Msg: fmt.Sprintf("unknown class %q, expected one of: %s", query.Class, strings.Join(cfa.Classes, ", "))})
+	}
+	if f, ok := e.fields[leadTimeField]; ok {
+		d, err := asLeadTime(f.value)
+		if err != nil {
+			errs = append(errs, Error{Line: f.line, Field: path(leadTimeField), Msg: err.Error()})
+		}
+		query.LeadTime = d
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &query, nil
+}
+
+func knownShorthandClass(class string) bool {
+	for i := range cfa.Classes {
+		if shorthand.Matches(class, cfa.Classes[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func knownClass(name string) bool {
+	for i := range cfa.Classes {
+		if strings.Contains(cfa.Classes[i], name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func asTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q, expected RFC3339 like 2006-01-02T15:04:05-07:00", t)
+		}
+		return parsed, nil
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %v, expected an RFC3339 string", v)
+	}
+}
+
+func asLeadTime(v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid lead time %v, expected a duration string like 10m", v)
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lead time %q, expected a duration like 10m", s)
+	}
+	if d <= 0 || d > cfa.MaxLeadTime {
+		return 0, fmt.Errorf("lead time %s must be more than 0 and at most %s", d, cfa.MaxLeadTime)
+	}
+
+	return d, nil
+}
+
+func asInt(v interface{}) (int, bool) {
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int64:
+		return int(n), true
+	case float64:
+		if n != math.Trunc(n) {
+			return 0, false
+		}
+		return int(n), true
+	default:
+		return 0, false
+	}
+}
+
+func sortedKeys(m map[string]field) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,88 @@
+package requestfile
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+func parseYAML(data []byte) (*document, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		// yaml errors already include the line number
+		return nil, Errors{{Msg: err.Error()}}
+	}
+	if len(root.Content) == 0 {
+		return nil, Errors{{Line: 1, Msg: "file is empty"}}
+	}
+
+	node := root.Content[0]
+	switch node.Kind {
+	case yaml.SequenceNode:
+		requests, err := readYAMLEntries(node)
+		if err != nil {
+			return nil, err
+		}
+		return &document{legacy: true, requests: requests}, nil
+	case yaml.MappingNode:
+		doc := document{fields: make(map[string]field)}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == requestsField {
+				if value.Kind != yaml.SequenceNode {
+					return nil, Errors{{Line: value.Line, Field: requestsField, Msg: "must be a list"}}
+				}
+				requests, err := readYAMLEntries(value)
+				if err != nil {
+					return nil, err
+				}
+				doc.requests = requests
+				doc.fields[key.Value] = field{line: key.Line}
+				continue
+			}
+			v, err := yamlValue(value)
+			if err != nil {
+				return nil, err
+			}
+			doc.fields[key.Value] = field{value: v, line: key.Line}
+		}
+		return &doc, nil
+	default:
+		return nil, Errors{{Line: node.Line, Msg: "expected a mapping with version and requests"}}
+	}
+}
+
+func readYAMLEntries(list *yaml.Node) ([]entry, error) {
+	entries := make([]entry, 0, len(list.Content))
+	for i, node := range list.Content {
+		if node.Kind != yaml.MappingNode {
+			return nil, Errors{{Line: node.Line, Field: fmt.Sprintf("%s[%d]", requestsField, i), Msg: "must be a mapping"}}
+		}
+		e := entry{line: node.Line, fields: make(map[string]field)}
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			key, value := node.Content[j], node.Content[j+1]
+			v, err := yamlValue(value)
+			if err != nil {
+				return nil, err
+			}
+			e.fields[key.Value] = field{value: v, line: key.Line}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// yamlValue decodes a node into a plain go value. Timestamps are kept as
+// strings so that they go through the same validation as the other formats.
+func yamlValue(node *yaml.Node) (interface{}, error) {
+	if node.Kind == yaml.ScalarNode && node.ShortTag() == "!!timestamp" {
+		return node.Value, nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return nil, Errors{{Line: node.Line, Msg: err.Error()}}
+	}
+
+	return v, nil
+}
@@ -0,0 +1,126 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrTriggerNotFound is returned by backends when a trigger doesn't exist.
+var ErrTriggerNotFound = errors.New("trigger not found")
+
+// Trigger fires a TaskRequest at a given time.
+type Trigger struct {
+	Name        string      `json:"name"`
+	ID          string      `json:"id,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Time        time.Time   `json:"time"`
+	State       string      `json:"state,omitempty"`
+	Task        TaskRequest `json:"task"`
+}
+
+// TriggerBackend stores triggers and runs their task requests when they fire.
+type TriggerBackend interface {
+	// Create creates a trigger and returns the backend's id for it.
+	Create(t Trigger) (string, error)
+	// Update replaces the trigger with the same name and returns its id, or
+	// returns ErrTriggerNotFound.
+	Update(t Trigger) (string, error)
+	// List returns every trigger created by the scheduler.
+	List() ([]Trigger, error)
+	// Get returns the trigger with the given name or ErrTriggerNotFound.
+	Get(name string) (*Trigger, error)
+	// Delete deletes the trigger with the given name or returns
+	// ErrTriggerNotFound.
+	Delete(name string) error
+}
+
+// groupEnsurer is implemented by backends whose triggers live in a group
+// that has to exist before triggers are created in it.
+type groupEnsurer interface {
+	EnsureGroup() error
+}
+
+// logSetter is implemented by backends that log progress.
+type logSetter interface {
+	SetLog(w io.Writer)
+}
+
+// MemoryBackend keeps triggers in memory and never fires them. It is meant
+// for tests and dry runs.
+type MemoryBackend struct {
+	mu       sync.Mutex
+	triggers map[string]Trigger
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{triggers: make(map[string]Trigger)}
+}
+
+func (b *MemoryBackend) Create(t Trigger) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.triggers[t.Name]; ok {
+		return "", fmt.Errorf("trigger %s already exists", t.Name)
+	}
+	t.ID = "memory:" + t.Name
+	b.triggers[t.Name] = t
+
+	return t.ID, nil
+}
+
+func (b *MemoryBackend) Update(t Trigger) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.triggers[t.Name]; !ok {
+		return "", ErrTriggerNotFound
+	}
+	t.ID = "memory:" + t.Name
+	b.triggers[t.Name] = t
+
+	return t.ID, nil
+}
+
+func (b *MemoryBackend) List() ([]Trigger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	triggers := make([]Trigger, 0, len(b.triggers))
+	for _, t := range b.triggers {
+		triggers = append(triggers, t)
+	}
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].Time.Before(triggers[j].Time)
+	})
+
+	return triggers, nil
+}
+
+func (b *MemoryBackend) Get(name string) (*Trigger, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.triggers[name]
+	if !ok {
+		return nil, ErrTriggerNotFound
+	}
+
+	return &t, nil
+}
+
+func (b *MemoryBackend) Delete(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.triggers[name]; !ok {
+		return ErrTriggerNotFound
+	}
+	delete(b.triggers, name)
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryBackend(t *testing.T) {
+	b := NewMemoryBackend()
+	now := time.Now()
+	later := Trigger{Name: "Schedule.later", Time: now.Add(time.Hour)}
+	sooner := Trigger{Name: "Schedule.sooner", Time: now.Add(time.Minute)}
+
+	for _, tr := range []Trigger{later, sooner} {
+		if _, err := b.Create(tr); err != nil {
+			t.Fatalf("unable to create %s: %v", tr.Name, err)
+		}
+	}
+	if _, err := b.Create(later); err == nil {
+		t.Error("got no error creating an existing trigger")
+	}
+
+	triggers, err := b.List()
+	if err != nil {
+		t.Fatalf("unable to list triggers: %v", err)
+	}
+	if len(triggers) != 2 || triggers[0].Name != sooner.Name {
+		t.Errorf("got %v, want both triggers soonest first", triggers)
+	}
+
+	later.Time = now.Add(2 * time.Hour)
+	if _, err := b.Update(later); err != nil {
+		t.Fatalf("unable to update %s: %v", later.Name, err)
+	}
+	got, err := b.Get(later.Name)
+	if err != nil {
+		t.Fatalf("unable to get %s: %v", later.Name, err)
+	}
+	if !got.Time.Equal(later.Time) || got.ID == "" {
+		t.Errorf("got %+v, want the updated trigger with an id", got)
+	}
+
+	if err := b.Delete(later.Name); err != nil {
+		t.Fatalf("unable to delete %s: %v", later.Name, err)
+	}
+	if _, err := b.Get(later.Name); err != ErrTriggerNotFound {
+		t.Errorf("got %v getting a deleted trigger, want %v", err, ErrTriggerNotFound)
+	}
+	if _, err := b.Update(later); err != ErrTriggerNotFound {
+		t.Errorf("got %v updating a deleted trigger, want %v", err, ErrTriggerNotFound)
+	}
+	if err := b.Delete(later.Name); err != ErrTriggerNotFound {
+		t.Errorf("got %v deleting a deleted trigger, want %v", err, ErrTriggerNotFound)
+	}
+}
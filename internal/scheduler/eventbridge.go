@@ -0,0 +1,321 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/scheduler"
+)
+
+const (
+	expressionLayout = "at(2006-01-02T15:04:05)"
+	disabledState    = scheduler.ScheduleStateDisabled
+	defaultGroup     = "default"
+)
+
+// EventBridgeConfig is where the EventBridge backend creates schedules and
+// what they invoke.
+type EventBridgeConfig struct {
+	// Timezone schedule expressions are written in.
+	Timezone string
+	// LambdaARN is the rsvp lambda schedules invoke.
+	LambdaARN string
+	// RoleARN is the role EventBridge Scheduler assumes to invoke the lambda.
+	RoleARN string
+	// Group is the schedule group schedules are created in.
+	Group string
+	// Tags are applied to the schedule group when it's created. Schedules
+	// themselves can't be tagged.
+	Tags map[string]string
+	// MaxRetries is how many times EventBridge retries invoking the lambda
+	// when the invocation fails.
+	MaxRetries int64
+	// MaxEventAge stops retries once the invocation is this old. Zero uses
+	// the EventBridge default.
+	MaxEventAge time.Duration
+	// DeadLetterARN is the SQS queue invocations that still fail after
+	// retrying are sent to. Empty drops them.
+	DeadLetterARN string
+}
+
+// EventBridgeBackend creates triggers as one time EventBridge Scheduler
+// schedules that invoke the rsvp lambda.
+type EventBridgeBackend struct {
+	client *scheduler.Scheduler
+	cfg    EventBridgeConfig
+	loc    *time.Location
+	log    io.Writer
+}
+
+func NewEventBridgeBackend(sess *session.Session, cfg EventBridgeConfig) (*EventBridgeBackend, error) {
+	if sess == nil {
+		return nil, fmt.Errorf("session cannot be nil")
+	}
+	if cfg.LambdaARN == "" || cfg.RoleARN == "" {
+		return nil, fmt.Errorf("lambda and role arns are required")
+	}
+	if cfg.Group == "" {
+		return nil, fmt.Errorf("schedule group cannot be empty")
+	}
+	loc, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load timezone %s: %w", cfg.Timezone, err)
+	}
+
+	return &EventBridgeBackend{client: scheduler.New(sess), cfg: cfg, loc: loc, log: os.Stdout}, nil
+}
+
+// SetLog sets where progress and warnings are logged, stdout by default.
+func (b *EventBridgeBackend) SetLog(w io.Writer) {
+	b.log = w
+}
+
+// EnsureGroup creates the schedule group, with the configured tags, if it
+// doesn't exist yet. The default group always exists and can't be tagged.
+func (b *EventBridgeBackend) EnsureGroup() error {
+	if b.cfg.Group == defaultGroup {
+		return nil
+	}
+	_, err := b.client.GetScheduleGroup(&scheduler.GetScheduleGroupInput{Name: aws.String(b.cfg.Group)})
+	if err == nil {
+		return nil
+	}
+	var notFound *scheduler.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("unable to get schedule group %s: %w", b.cfg.Group, err)
+	}
+
+	input := scheduler.CreateScheduleGroupInput{Name: aws.String(b.cfg.Group)}
+	for k, v := range b.cfg.Tags {
+		input.Tags = append(input.Tags, &scheduler.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	if _, err := b.client.CreateScheduleGroup(&input); err != nil {
+		return fmt.Errorf("unable to create schedule group %s: %w", b.cfg.Group, err)
+	}
+	fmt.Fprintf(b.log, "created schedule group %s\n", b.cfg.Group)
+
+	return nil
+}
+
+func (b *EventBridgeBackend) Create(t Trigger) (string, error) {
+	target, err := b.target(t)
+	if err != nil {
+		return "", err
+	}
+	event := scheduler.CreateScheduleInput{
+		// one time schedules stay around after they fire unless told
+		// otherwise
+		ActionAfterCompletion:      aws.String(scheduler.ActionAfterCompletionDelete),
+		Description:                aws.String(t.Description),
+		GroupName:                  aws.String(b.cfg.Group),
+		Name:                       aws.String(t.Name),
+		ScheduleExpression:         aws.String(formScheduleExpression(t.Time.In(b.loc))),
+		ScheduleExpressionTimezone: aws.String(b.cfg.Timezone),
+		FlexibleTimeWindow: &scheduler.FlexibleTimeWindow{
+			Mode: aws.String(scheduler.FlexibleTimeWindowModeOff),
+		},
+		Target: target,
+	}
+
+	resp, err := b.client.CreateSchedule(&event)
+	if err != nil {
+		return "", fmt.Errorf("unable to create scheduled event: %w", err)
+	}
+
+	return *resp.ScheduleArn, nil
+}
+
+func (b *EventBridgeBackend) Update(t Trigger) (string, error) {
+	target, err := b.target(t)
+	if err != nil {
+		return "", err
+	}
+	event := scheduler.UpdateScheduleInput{
+		ActionAfterCompletion:      aws.String(scheduler.ActionAfterCompletionDelete),
+		Description:                aws.String(t.Description),
+		GroupName:                  aws.String(b.cfg.Group),
+		Name:                       aws.String(t.Name),
+		ScheduleExpression:         aws.String(formScheduleExpression(t.Time.In(b.loc))),
+		ScheduleExpressionTimezone: aws.String(b.cfg.Timezone),
+		FlexibleTimeWindow: &scheduler.FlexibleTimeWindow{
+			Mode: aws.String(scheduler.FlexibleTimeWindowModeOff),
+		},
+		State:  aws.String(scheduler.ScheduleStateEnabled),
+		Target: target,
+	}
+
+	resp, err := b.client.UpdateSchedule(&event)
+	var notFound *scheduler.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return "", ErrTriggerNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("unable to update scheduled event: %w", err)
+	}
+
+	return *resp.ScheduleArn, nil
+}
+
+func (b *EventBridgeBackend) target(t Trigger) (*scheduler.Target, error) {
+	input, err := json.Marshal(t.Task)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal task request: %w", err)
+	}
+
+	target := scheduler.Target{
+		Arn:     aws.String(b.cfg.LambdaARN),
+		RoleArn: aws.String(b.cfg.RoleARN),
+		Input:   aws.String(string(input)),
+		RetryPolicy: &scheduler.RetryPolicy{
+			MaximumRetryAttempts: aws.Int64(b.cfg.MaxRetries),
+		},
+	}
+	if b.cfg.MaxEventAge > 0 {
+		target.RetryPolicy.MaximumEventAgeInSeconds = aws.Int64(int64(b.cfg.MaxEventAge / time.Second))
+	}
+	if b.cfg.DeadLetterARN != "" {
+		target.DeadLetterConfig = &scheduler.DeadLetterConfig{
+			Arn: aws.String(b.cfg.DeadLetterARN),
+		}
+	}
+
+	return &target, nil
+}
+
+func (b *EventBridgeBackend) List() ([]Trigger, error) {
+	var names []string
+	input := scheduler.ListSchedulesInput{
+		GroupName:  aws.String(b.cfg.Group),
+		NamePrefix: aws.String(triggerNamePrefix),
+	}
+	err := b.client.ListSchedulesPages(&input, func(out *scheduler.ListSchedulesOutput, last bool) bool {
+		for _, summary := range out.Schedules {
+			names = append(names, aws.StringValue(summary.Name))
+		}
+		return true
+	})
+	var notFound *scheduler.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		// the group is only created once the first trigger is
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to list scheduled events: %w", err)
+	}
+
+	triggers := make([]Trigger, 0, len(names))
+	for _, name := range names {
+		out, err := b.get(name)
+		if errors.Is(err, ErrTriggerNotFound) {
+			// deleted since it was listed
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		t, err := toTrigger(out)
+		if err != nil {
+			// a schedule made by hand or by something else sharing the
+			// group shouldn't hide every other trigger
+			fmt.Fprintf(b.log, "warning: skipping scheduled event %s: %v\n", name, err)
+			continue
+		}
+		triggers = append(triggers, t)
+	}
+
+	return triggers, nil
+}
+
+func (b *EventBridgeBackend) Get(name string) (*Trigger, error) {
+	out, err := b.get(name)
+	if err != nil {
+		return nil, err
+	}
+	t, err := toTrigger(out)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode scheduled event %s: %w", name, err)
+	}
+
+	return &t, nil
+}
+
+func (b *EventBridgeBackend) get(name string) (*scheduler.GetScheduleOutput, error) {
+	out, err := b.client.GetSchedule(&scheduler.GetScheduleInput{
+		GroupName: aws.String(b.cfg.Group),
+		Name:      aws.String(name),
+	})
+	var notFound *scheduler.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return nil, ErrTriggerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to get scheduled event %s: %w", name, err)
+	}
+
+	return out, nil
+}
+
+func (b *EventBridgeBackend) Delete(name string) error {
+	_, err := b.client.DeleteSchedule(&scheduler.DeleteScheduleInput{
+		GroupName: aws.String(b.cfg.Group),
+		Name:      aws.String(name),
+	})
+	var notFound *scheduler.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		return ErrTriggerNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("unable to delete scheduled event %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func toTrigger(out *scheduler.GetScheduleOutput) (Trigger, error) {
+	trigger := Trigger{
+		Name:        aws.StringValue(out.Name),
+		ID:          aws.StringValue(out.Arn),
+		Description: aws.StringValue(out.Description),
+		State:       aws.StringValue(out.State),
+	}
+
+	loc := time.UTC
+	if tz := aws.StringValue(out.ScheduleExpressionTimezone); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return Trigger{}, fmt.Errorf("unable to load timezone %s: %w", tz, err)
+		}
+		loc = l
+	}
+	t, err := time.ParseInLocation(expressionLayout, aws.StringValue(out.ScheduleExpression), loc)
+	if err != nil {
+		return Trigger{}, fmt.Errorf("unable to parse schedule expression: %w", err)
+	}
+	trigger.Time = t
+
+	if out.Target == nil {
+		return Trigger{}, fmt.Errorf("scheduled event has no target")
+	}
+	if err := json.Unmarshal([]byte(aws.StringValue(out.Target.Input)), &trigger.Task); err != nil {
+		return Trigger{}, fmt.Errorf("unable to unmarshal task request: %w", err)
+	}
+
+	return trigger, nil
+}
+
+func formScheduleExpression(start time.Time) string {
+	return fmt.Sprintf(
+		"at(%d-%02d-%02dT%02d:%02d:00)",
+		start.Year(),
+		start.Month(),
+		start.Day(),
+		start.Hour(),
+		start.Minute(),
+	)
+}
@@ -0,0 +1,91 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// schedulerStub answers ListSchedules and GetSchedule with the schedules'
+// GetSchedule payloads, keyed by name.
+func schedulerStub(t *testing.T, schedules map[string]map[string]interface{}) *EventBridgeBackend {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/schedules" {
+			var summaries []map[string]string
+			for name := range schedules {
+				summaries = append(summaries, map[string]string{"Name": name})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Schedules": summaries})
+			return
+		}
+		schedule, ok := schedules[strings.TrimPrefix(r.URL.Path, "/schedules/")]
+		if !ok {
+			w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"Message": "not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(schedule)
+	}))
+	t.Cleanup(srv.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-2"),
+		Endpoint:    aws.String(srv.URL),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("unable to create session: %v", err)
+	}
+	b, err := NewEventBridgeBackend(sess, EventBridgeConfig{
+		Timezone:  "UTC",
+		LambdaARN: "arn:aws:lambda:us-east-2:123456789012:function:RSVPer",
+		RoleARN:   "arn:aws:iam::123456789012:role/rsvper",
+		Group:     "rsvper",
+	})
+	if err != nil {
+		t.Fatalf("unable to create backend: %v", err)
+	}
+
+	return b
+}
+
+func TestEventBridgeListSkipsUndecodableSchedules(t *testing.T) {
+	schedule := func(name, expression, input string) map[string]interface{} {
+		return map[string]interface{}{
+			"Name":                       name,
+			"Arn":                        "arn:aws:scheduler:us-east-2:123456789012:schedule/rsvper/" + name,
+			"ScheduleExpression":         expression,
+			"ScheduleExpressionTimezone": "UTC",
+			"Target":                     map[string]string{"Arn": "arn:aws:lambda:us-east-2:123456789012:function:RSVPer", "Input": input},
+		}
+	}
+	b := schedulerStub(t, map[string]map[string]interface{}{
+		"Schedule.ours":    schedule("Schedule.ours", "at(2026-10-15T17:25:00)", `{"schedule": {"id": 42}, "account": "me@example.com"}`),
+		"Schedule.cron":    schedule("Schedule.cron", "cron(0 12 * * ? *)", `{}`),
+		"Schedule.foreign": schedule("Schedule.foreign", "at(2026-10-15T17:25:00)", `not json`),
+	})
+
+	triggers, err := b.List()
+	if err != nil {
+		t.Fatalf("unable to list triggers: %v", err)
+	}
+	if len(triggers) != 1 || triggers[0].Name != "Schedule.ours" || triggers[0].Task.Schedule.ID != 42 {
+		t.Errorf("got %+v, want only Schedule.ours", triggers)
+	}
+
+	if _, err := b.Get("Schedule.foreign"); err == nil {
+		t.Error("got no error getting an undecodable schedule")
+	}
+	if _, err := b.Get("Schedule.missing"); err != ErrTriggerNotFound {
+		t.Errorf("got %v getting a missing schedule, want %v", err, ErrTriggerNotFound)
+	}
+}
@@ -0,0 +1,24 @@
+package scheduler
+
+import (
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// Verdict is a filter's decision about a matched class.
+type Verdict int
+
+const (
+	// Allow schedules the class as usual.
+	Allow Verdict = iota
+	// Flag schedules the class but records a warning on the plan item.
+	Flag
+	// Skip does not schedule the class.
+	Skip
+)
+
+// Filter is consulted for every matched class before a trigger is created,
+// letting callers skip or flag classes, e.g. during blackout periods. The
+// returned string explains the verdict.
+type Filter interface {
+	Filter(sched cfa.Schedule) (Verdict, string, error)
+}
@@ -0,0 +1,139 @@
+package scheduler
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+// Action is what the scheduler decided to do with a requested class.
+type Action string
+
+const (
+	ActionCreate    Action = "create"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	ActionSkip      Action = "skip"
+	ActionDelete    Action = "delete"
+	// ActionBook books a class whose rsvp window is already open instead of
+	// creating a trigger for it.
+	ActionBook Action = "book"
+)
+
+// PlanItem records the outcome for a single request/class pair. Schedule is
+// nil when the request did not match any class in the fetched schedule, and
+// Request is nil for triggers being deleted.
+type PlanItem struct {
+	Request     *cfa.ScheduleRequest `json:"request,omitempty"`
+	Schedule    *cfa.Schedule        `json:"schedule,omitempty"`
+	Action      Action               `json:"action"`
+	Reason      string               `json:"reason,omitempty"`
+	TriggerName string               `json:"triggerName,omitempty"`
+	TriggerTime *time.Time           `json:"triggerTime,omitempty"`
+	TriggerID   string               `json:"triggerId,omitempty"`
+	// WindowOpen is when the class's rsvp window opens.
+	WindowOpen *time.Time `json:"windowOpen,omitempty"`
+	// Status is the rsvp status after booking a class right away.
+	Status   string   `json:"status,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+
+	// trigger is what gets created or updated when the plan is applied.
+	trigger *Trigger
+	// task is what gets booked when the plan is applied.
+	task *TaskRequest
+}
+
+// Plan is the list of decisions made while processing requests.
+type Plan struct {
+	Items []PlanItem `json:"items"`
+}
+
+func (p *Plan) add(item PlanItem) {
+	p.Items = append(p.Items, item)
+}
+
+// Count returns the number of items with the given action.
+func (p *Plan) Count(action Action) int {
+	var n int
+	for i := range p.Items {
+		if p.Items[i].Action == action {
+			n++
+		}
+	}
+
+	return n
+}
+
+// HasChanges reports whether applying the plan would change anything.
+func (p *Plan) HasChanges() bool {
+	return p.Count(ActionCreate)+p.Count(ActionUpdate)+p.Count(ActionDelete)+p.Count(ActionBook) > 0
+}
+
+// WriteSummary writes a human readable summary of the plan to w.
+func (p *Plan) WriteSummary(w io.Writer) {
+	for i := range p.Items {
+		item := p.Items[i]
+		var class string
+		if item.Request != nil {
+			class = fmt.Sprintf("%s @ %s", item.Request.ClassName, formatTime(item.Request.StartTime))
+		}
+		if item.Schedule != nil {
+			class = fmt.Sprintf("%s (id: %d) @ %s", classTitle(*item.Schedule), item.Schedule.ID, formatTime(item.Schedule.Start))
+		}
+		var window string
+		if item.WindowOpen != nil {
+			window = ", window opens " + formatTime(item.WindowOpen)
+		}
+		switch item.Action {
+		case ActionCreate:
+			fmt.Fprintf(w, "  + %s, trigger at %s%s\n", class, formatTime(item.TriggerTime), window)
+		case ActionUpdate:
+			fmt.Fprintf(w, "  ~ %s, trigger at %s%s\n", class, formatTime(item.TriggerTime), window)
+		case ActionUnchanged:
+			fmt.Fprintf(w, "  = %s, trigger at %s%s\n", class, formatTime(item.TriggerTime), window)
+		case ActionSkip:
+			fmt.Fprintf(w, "  - %s, skipped: %s\n", class, item.Reason)
+		case ActionBook:
+			switch {
+			case item.Status != "":
+				fmt.Fprintf(w, "  > %s, booked: %s\n", class, item.Status)
+			case item.Reason != "":
+				fmt.Fprintf(w, "  > %s, %s\n", class, item.Reason)
+			default:
+				fmt.Fprintf(w, "  > %s, book now, window opens %s\n", class, formatTime(item.WindowOpen))
+			}
+		case ActionDelete:
+			fmt.Fprintf(w, "  x %s, delete %s: %s\n", class, item.TriggerName, item.Reason)
+		}
+		for _, warning := range item.Warnings {
+			fmt.Fprintf(w, "    ! %s\n", warning)
+		}
+	}
+	fmt.Fprintf(
+		w,
+		"%d created, %d updated, %d unchanged, %d booked now, %d skipped, %d deleted\n",
+		p.Count(ActionCreate),
+		p.Count(ActionUpdate),
+		p.Count(ActionUnchanged),
+		p.Count(ActionBook),
+		p.Count(ActionSkip),
+		p.Count(ActionDelete),
+	)
+}
+
+// formatTime formats t for the summary. Triggers made by hand or by older
+// versions can be missing times.
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "unknown time"
+	}
+
+	return t.Format(time.RFC3339)
+}
+
+func classTitle(sched cfa.Schedule) string {
+	return strings.Replace(sched.Title, "\n", " ", 1)
+}
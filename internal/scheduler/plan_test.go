@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+func TestWriteSummary(t *testing.T) {
+	start := time.Date(2026, 10, 20, 17, 30, 0, 0, time.UTC)
+	windowOpen := start.Add(-cfa.MinimumRSVPTime)
+	triggerTime := windowOpen.Add(-cfa.DefaultLeadTime)
+	sched := cfa.Schedule{ID: 42, Title: "CrossFit\nSmall Group", Start: &start}
+	// a trigger left by hand or an older version can lack a start
+	noStart := cfa.Schedule{ID: 42, Title: "CrossFit\nSmall Group"}
+
+	tests := []struct {
+		name string
+		item PlanItem
+		want string
+	}{
+		{
+			name: "create",
+			item: PlanItem{Schedule: &sched, Action: ActionCreate, TriggerTime: &triggerTime, WindowOpen: &windowOpen},
+			want: "  + CrossFit Small Group (id: 42) @ 2026-10-20T17:30:00Z, trigger at 2026-10-15T17:25:00Z, window opens 2026-10-15T17:30:00Z",
+		},
+		{
+			name: "update",
+			item: PlanItem{Schedule: &sched, Action: ActionUpdate, TriggerTime: &triggerTime, WindowOpen: &windowOpen},
+			want: "  ~ CrossFit Small Group (id: 42) @ 2026-10-20T17:30:00Z, trigger at 2026-10-15T17:25:00Z, window opens 2026-10-15T17:30:00Z",
+		},
+		{
+			name: "unchanged",
+			item: PlanItem{Schedule: &sched, Action: ActionUnchanged, TriggerTime: &triggerTime, WindowOpen: &windowOpen},
+			want: "  = CrossFit Small Group (id: 42) @ 2026-10-20T17:30:00Z, trigger at 2026-10-15T17:25:00Z, window opens 2026-10-15T17:30:00Z",
+		},
+		{
+			name: "without a window",
+			item: PlanItem{Schedule: &sched, Action: ActionCreate, TriggerTime: &triggerTime},
+			want: "  + CrossFit Small Group (id: 42) @ 2026-10-20T17:30:00Z, trigger at 2026-10-15T17:25:00Z",
+		},
+		{
+			name: "book now",
+			item: PlanItem{Schedule: &sched, Action: ActionBook, WindowOpen: &windowOpen},
+			want: "  > CrossFit Small Group (id: 42) @ 2026-10-20T17:30:00Z, book now, window opens 2026-10-15T17:30:00Z",
+		},
+		{
+			name: "delete without a start",
+			item: PlanItem{Schedule: &noStart, Action: ActionDelete, Reason: "no longer requested", TriggerName: "Schedule.x"},
+			want: "  x CrossFit Small Group (id: 42) @ unknown time, delete Schedule.x: no longer requested",
+		},
+		{
+			name: "request without a start",
+			item: PlanItem{Request: &cfa.ScheduleRequest{ClassName: "Small Group"}, Action: ActionSkip, Reason: "no matching class"},
+			want: "  - Small Group @ unknown time, skipped: no matching class",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := Plan{Items: []PlanItem{tt.item}}
+			var out bytes.Buffer
+			plan.WriteSummary(&out)
+
+			got, _, _ := strings.Cut(out.String(), "\n")
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
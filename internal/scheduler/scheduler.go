@@ -1,142 +1,530 @@
 package scheduler
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-
-	"github.com/aws/aws-sdk-go/service/scheduler"
 	"github.com/itsHabib/rsvper/internal/cfa"
 )
 
-const (
-	chicagoTZ        = "America/Chicago"
-	rsvperLambdaARN  = "arn:aws:lambda:us-east-2:273568070039:function:RSVPer"
-	schedulerRoleARN = "arn:aws:iam::273568070039:role/service-role/Amazon_EventBridge_Scheduler_LAMBDA_9d397e263b"
-)
-
 type TaskRequest struct {
-	Schedule  cfa.Schedule `json:"schedule"`
-	CFACookie cfa.Cookie   `json:"cfaCookie"`
+	Schedule cfa.Schedule `json:"schedule"`
+	// Credentials references the account's credentials in the secret store.
+	// They are resolved and used to log in when the task runs, so the task
+	// never carries a session.
+	Credentials string `json:"credentials"`
+	// Account is the username the task books for.
+	Account string `json:"account,omitempty"`
+}
+
+// Key identifies the task for deduping invocations. It's the same for every
+// invocation of a trigger, and for triggers planned again for the same class.
+func (t TaskRequest) Key() string {
+	account := t.Account
+	if account == "" {
+		account = t.Credentials
+	}
+	var start int64
+	if t.Schedule.Start != nil {
+		start = t.Schedule.Start.Unix()
+	}
+
+	return fmt.Sprintf("%s/%d/%d", account, t.Schedule.ID, start)
+}
+
+// Booker books a class right away, used for classes whose rsvp window is
+// already open.
+type Booker interface {
+	Book(ctx context.Context, task TaskRequest) (cfa.RSVPStatus, error)
+}
+
+// RSVPChecker reports the account's current RSVP status for a class.
+type RSVPChecker interface {
+	CheckRSVP(sched cfa.Schedule) (cfa.RSVPStatus, error)
 }
 
 type Service struct {
-	sess *session.Session
+	backend        TriggerBackend
+	checker        RSVPChecker
+	booker         Booker
+	filters        []Filter
+	account        string
+	calendar       string
+	credentials    string
+	leadTime       time.Duration
+	classLeadTimes map[string]time.Duration
+	log            io.Writer
 }
 
-func NewService(sess *session.Session) (*Service, error) {
-	if sess == nil {
-		return nil, fmt.Errorf("session cannot be nil")
+func NewService(backend TriggerBackend, checker RSVPChecker) (*Service, error) {
+	if backend == nil {
+		return nil, fmt.Errorf("trigger backend cannot be nil")
 	}
+	if checker == nil {
+		return nil, fmt.Errorf("rsvp checker cannot be nil")
+	}
+
+	return &Service{
+		backend:  backend,
+		checker:  checker,
+		calendar: cfa.InHouseSessions,
+		leadTime: cfa.DefaultLeadTime,
+		log:      os.Stdout,
+	}, nil
+}
+
+// SetLog sets where progress is logged, stdout by default. Backends that log
+// log there too.
+func (s *Service) SetLog(w io.Writer) {
+	s.log = w
+	if l, ok := s.backend.(logSetter); ok {
+		l.SetLog(w)
+	}
+}
+
+// SetAccount sets the account triggers are created for. Triggers for the same
+// class but different accounts don't collide.
+func (s *Service) SetAccount(account string) {
+	s.account = account
+}
+
+// SetCredentials sets the credentials reference put in every task request.
+func (s *Service) SetCredentials(ref string) {
+	s.credentials = ref
+}
+
+// SetLeadTime sets how long before the rsvp window opens triggers fire,
+// unless a request or class overrides it.
+func (s *Service) SetLeadTime(d time.Duration) {
+	s.leadTime = d
+}
+
+// SetClassLeadTime overrides the lead time for classes whose title contains
+// class.
+func (s *Service) SetClassLeadTime(class string, d time.Duration) {
+	if s.classLeadTimes == nil {
+		s.classLeadTimes = make(map[string]time.Duration)
+	}
+	s.classLeadTimes[class] = d
+}
+
+// SetBooker sets the booker used for classes that can already be booked.
+// Without one, those classes get a trigger that fires shortly instead.
+func (s *Service) SetBooker(b Booker) {
+	s.booker = b
+}
+
+// SetCalendar sets the calendar the schedule was fetched from, which is part
+// of each trigger's identity.
+func (s *Service) SetCalendar(calendar string) {
+	s.calendar = calendar
+}
 
-	return &Service{sess: sess}, nil
+// AddFilter adds a filter that is consulted for every matched class.
+func (s *Service) AddFilter(f Filter) {
+	s.filters = append(s.filters, f)
 }
 
-func (s *Service) ProcessRequests(cookie cfa.Cookie, schedules []cfa.Schedule, requests []cfa.ScheduleRequest) error {
+// ProcessRequests plans triggers for the requests and applies the plan.
+func (s *Service) ProcessRequests(ctx context.Context, schedules []cfa.Schedule, requests []cfa.ScheduleRequest) (*Plan, error) {
+	plan, err := s.Plan(schedules, requests)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Apply(ctx, plan); err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+// Plan matches requests against the schedule and decides which trigger each
+// matched class needs, without changing anything. Classes that match more than
+// one request, that a filter rejects, or that the account is already RSVP'd or
+// waitlisted for, are skipped, and the plan records why. Classes that can
+// already be booked are booked when the plan is applied, if the service has a
+// booker. Triggers left behind for classes that already started are planned
+// for deletion.
+func (s *Service) Plan(schedules []cfa.Schedule, requests []cfa.ScheduleRequest) (*Plan, error) {
+	if s.credentials == "" {
+		return nil, fmt.Errorf("credentials reference cannot be empty")
+	}
+
+	var plan Plan
+	if err := s.planGarbage(&plan, time.Now()); err != nil {
+		return nil, err
+	}
+
 	// sort schedules and requests by time
 
 	sort.Slice(schedules, func(i, j int) bool {
 		return schedules[i].Start.Before(*schedules[j].Start)
 	})
 
+	planned := make(map[int]bool)
 	for i := range requests {
-		fmt.Printf("request: %s, %s\n", requests[i].ClassName, requests[i].StartTime.Format(time.RFC3339))
+		fmt.Fprintf(s.log, "request: %s, %s\n", requests[i].ClassName, requests[i].StartTime.Format(time.RFC3339))
+		var matched bool
 		for j := range schedules {
-			//	fmt.Printf("class schedules: %s, %s, %s\n", schedules[j].Title, schedules[j].Start, schedules[j].End)
-			if strings.Contains(schedules[j].Title, requests[i].ClassName) &&
-				equalTimes(*schedules[j].Start, *requests[i].StartTime) {
-				fmt.Printf("Found class: %s, %s\n", strings.Replace(schedules[j].Title, "\n", " ", 1), schedules[j].Start)
-				timeUntilClass := time.Until(*requests[i].StartTime)
-				fmt.Printf("time until class: %s\n", timeUntilClass)
-
-				var start time.Time
-				if timeUntilClass < cfa.MinimumRSVPTime {
-					start = time.Now().Add(3 * time.Minute)
-				} else {
-					start = schedules[j].Start.Add(-1 * (cfa.MinimumRSVPTime + 5*time.Minute))
-				}
+			if !MatchesRequest(schedules[j], requests[i]) {
+				continue
+			}
+			matched = true
+			fmt.Fprintf(s.log, "Found class: %s, %s\n", classTitle(schedules[j]), schedules[j].Start)
 
-				// create scheduled event for class
-				fmt.Printf("creating scheduled event for class at %s\n", start)
-				req := TaskRequest{
-					Schedule:  schedules[j],
-					CFACookie: cookie,
-				}
-				arn, err := s.createScheduledEvent(req, start)
-				if err != nil {
-					return fmt.Errorf("unable to create scheduled event: %w", err)
+			windowOpen := schedules[j].Start.Add(-cfa.MinimumRSVPTime)
+			item := PlanItem{
+				Request:    &requests[i],
+				Schedule:   &schedules[j],
+				WindowOpen: &windowOpen,
+			}
+			if planned[schedules[j].ID] {
+				item.Action = ActionSkip
+				item.Reason = "duplicate of an earlier request"
+				fmt.Fprintf(s.log, "skipping class: %s\n", item.Reason)
+				plan.add(item)
+				continue
+			}
+			planned[schedules[j].ID] = true
+
+			if err := s.applyFilters(&item); err != nil {
+				return nil, err
+			}
+			if item.Action == ActionSkip {
+				fmt.Fprintf(s.log, "skipping class: %s\n", item.Reason)
+				plan.add(item)
+				continue
+			}
+
+			status, err := s.checker.CheckRSVP(schedules[j])
+			if err != nil {
+				return nil, fmt.Errorf("unable to check rsvp status for class %d: %w", schedules[j].ID, err)
+			}
+			switch status {
+			case cfa.RSVPED:
+				item.Action = ActionSkip
+				item.Reason = "already rsvped"
+			case cfa.WAITLISTED:
+				item.Action = ActionSkip
+				item.Reason = "already on the wait list"
+			}
+			if item.Action == ActionSkip {
+				fmt.Fprintf(s.log, "skipping class: %s\n", item.Reason)
+				plan.add(item)
+				continue
+			}
+
+			timeUntilClass := time.Until(*requests[i].StartTime)
+			fmt.Fprintf(s.log, "time until class: %s\n", timeUntilClass)
+
+			task := TaskRequest{
+				Schedule:    schedules[j],
+				Credentials: s.credentials,
+				Account:     s.account,
+			}
+			lead := s.leadTimeFor(requests[i], schedules[j])
+			start := windowOpen.Add(-lead)
+			if !start.After(time.Now()) {
+				// the window is open or opens within the lead time
+				if s.booker != nil {
+					item.Action = ActionBook
+					item.task = &task
+					fmt.Fprintln(s.log, "class can be booked now")
+					plan.add(item)
+					continue
 				}
-				fmt.Printf("created scheduled event, arn: %s\n", arn)
+				start = s.soon(formTriggerName(s.calendar, s.account, schedules[j].ID))
+			}
+
+			trigger := Trigger{
+				Name:        formTriggerName(s.calendar, s.account, schedules[j].ID),
+				Description: formTriggerDescription(schedules[j]),
+				Time:        start,
+				Task:        task,
 			}
+			action, id, err := s.diff(trigger)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(s.log, "planned trigger %s for class at %s (%s)\n", trigger.Name, start, action)
+
+			item.Action = action
+			item.TriggerName = trigger.Name
+			item.TriggerTime = &start
+			item.TriggerID = id
+			item.trigger = &trigger
+			plan.add(item)
+		}
+		if !matched {
+			plan.add(PlanItem{
+				Request: &requests[i],
+				Action:  ActionSkip,
+				Reason:  "no matching class in schedule",
+			})
+			fmt.Fprintln(s.log, "skipping request: no matching class in schedule")
 		}
 	}
 
-	return nil
+	return &plan, nil
 }
 
-func (s *Service) createScheduledEvent(req TaskRequest, start time.Time) (string, error) {
-	input, err := json.Marshal(req)
+// PlanPrune treats the plan as the desired state and adds a deletion for
+// every pending trigger of the account and calendar that the plan doesn't
+// keep, e.g. for classes removed from the requests file or now blacked out.
+// Triggers that already fired are left to PurgeTriggers and CollectGarbage.
+func (s *Service) PlanPrune(plan *Plan, now time.Time) error {
+	keep := make(map[string]bool)
+	// classes that were requested but skipped lose their trigger for the
+	// same reason
+	reasons := make(map[string]string)
+	for i := range plan.Items {
+		item := plan.Items[i]
+		switch {
+		case item.TriggerName != "":
+			keep[item.TriggerName] = true
+		case item.Action == ActionSkip && item.Schedule != nil:
+			reasons[formTriggerName(s.calendar, s.account, item.Schedule.ID)] = item.Reason
+		}
+	}
+
+	triggers, err := s.ListTriggers()
 	if err != nil {
-		return "", fmt.Errorf("unable to marshal task request: %w", err)
-	}
-	client := scheduler.New(s.sess)
-
-	event := scheduler.CreateScheduleInput{
-		Description:                aws.String(formScheduledEventDescription(req.Schedule)),
-		Name:                       aws.String(formScheduledEventName(start)),
-		ScheduleExpression:         aws.String(formScheduleExpression(start)),
-		ScheduleExpressionTimezone: aws.String(chicagoTZ),
-		FlexibleTimeWindow: &scheduler.FlexibleTimeWindow{
-			Mode: aws.String(scheduler.FlexibleTimeWindowModeOff),
-		},
-		Target: &scheduler.Target{
-			Arn:     aws.String(rsvperLambdaARN),
-			RoleArn: aws.String(schedulerRoleARN),
-			Input:   aws.String(string(input)),
-			RetryPolicy: &scheduler.RetryPolicy{
-				MaximumRetryAttempts: aws.Int64(0),
-			},
-		},
-	}
-
-	resp, err := client.CreateSchedule(&event)
+		return err
+	}
+	for i := range triggers {
+		t := triggers[i]
+		if keep[t.Name] || !s.owns(t) || !t.Pending(now) {
+			continue
+		}
+		reason, ok := reasons[t.Name]
+		if !ok {
+			reason = "no longer requested"
+		}
+		class := t.Task.Schedule
+		plan.add(PlanItem{
+			Schedule:    &class,
+			Action:      ActionDelete,
+			Reason:      reason,
+			TriggerName: t.Name,
+			TriggerTime: &triggers[i].Time,
+			TriggerID:   t.ID,
+		})
+	}
+
+	return nil
+}
+
+// soon returns when a trigger for a class that can already be booked should
+// fire: a few minutes from now, on the minute since schedule expressions
+// don't have seconds, or the time of the class's pending trigger so planning
+// again doesn't keep pushing it back.
+func (s *Service) soon(name string) time.Time {
+	now := time.Now()
+	if existing, err := s.backend.Get(name); err == nil && existing.Pending(now) {
+		return existing.Time
+	}
+
+	return now.Add(3 * time.Minute).Truncate(time.Minute)
+}
+
+// leadTimeFor returns the lead time for the request's class: the request's
+// own, then the longest matching class override, then the default.
+func (s *Service) leadTimeFor(req cfa.ScheduleRequest, sched cfa.Schedule) time.Duration {
+	if req.LeadTime > 0 {
+		return req.LeadTime
+	}
+	var match string
+	for class := range s.classLeadTimes {
+		if strings.Contains(sched.Title, class) && len(class) > len(match) {
+			match = class
+		}
+	}
+	if match != "" {
+		return s.classLeadTimes[match]
+	}
+
+	return s.leadTime
+}
+
+// planGarbage plans the deletion of the account's triggers whose class
+// already started.
+func (s *Service) planGarbage(plan *Plan, now time.Time) error {
+	triggers, err := s.ListTriggers()
 	if err != nil {
-		return "", fmt.Errorf("unable to create scheduled event: %w", err)
+		return err
+	}
+	for i := range triggers {
+		class := triggers[i].Task.Schedule
+		if !s.owns(triggers[i]) || class.Start == nil || class.Start.After(now) {
+			continue
+		}
+		plan.add(PlanItem{
+			Schedule:    &class,
+			Action:      ActionDelete,
+			Reason:      "class already started",
+			TriggerName: triggers[i].Name,
+			TriggerTime: &triggers[i].Time,
+			TriggerID:   triggers[i].ID,
+		})
 	}
 
-	return *resp.ScheduleArn, nil
+	return nil
 }
 
-func formScheduleExpression(start time.Time) string {
-	return fmt.Sprintf(
-		"at(%d-%02d-%02dT%02d:%02d:00)",
-		start.Year(),
-		start.Month(),
-		start.Day(),
-		start.Hour(),
-		start.Minute(),
+// Apply carries out the plan's creates, updates and deletes, then books the
+// classes that can already be booked. Bookings poll until the rsvp window
+// opens, so they run concurrently once every trigger is in place rather than
+// holding up the rest of the plan. Items are updated with the action actually
+// taken, in case triggers changed since the plan was made. A failed booking
+// doesn't stop the others, it's recorded on the item and reported once
+// everything else is applied. Backends with a schedule group get it created
+// before the first trigger is written.
+func (s *Service) Apply(ctx context.Context, plan *Plan) error {
+	if g, ok := s.backend.(groupEnsurer); ok && plan.Count(ActionCreate)+plan.Count(ActionUpdate) > 0 {
+		if err := g.EnsureGroup(); err != nil {
+			return err
+		}
+	}
+
+	var book []*PlanItem
+	for i := range plan.Items {
+		item := &plan.Items[i]
+		switch item.Action {
+		case ActionDelete:
+			err := s.DeleteTrigger(item.TriggerName)
+			if err != nil && !errors.Is(err, ErrTriggerNotFound) {
+				return err
+			}
+			fmt.Fprintf(s.log, "deleted trigger %s\n", item.TriggerName)
+		case ActionCreate, ActionUpdate:
+			fmt.Fprintf(s.log, "upserting trigger %s for class at %s\n", item.TriggerName, item.TriggerTime)
+			action, id, err := s.upsert(*item.trigger)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(s.log, "upserted trigger (%s), id: %s\n", action, id)
+			item.Action = action
+			item.TriggerID = id
+		case ActionBook:
+			book = append(book, item)
+		}
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed int
 	)
+	for _, item := range book {
+		wg.Add(1)
+		go func(item *PlanItem) {
+			defer wg.Done()
+			fmt.Fprintf(s.log, "booking class %s now\n", classTitle(*item.Schedule))
+			status, err := s.booker.Book(ctx, *item.task)
+			if err != nil {
+				mu.Lock()
+				failed++
+				mu.Unlock()
+				item.Reason = fmt.Sprintf("booking failed: %v", err)
+				fmt.Fprintf(s.log, "unable to book class %d: %v\n", item.Schedule.ID, err)
+				return
+			}
+			item.Status = status.String()
+		}(item)
+	}
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("unable to book %d class(es)", failed)
+	}
+
+	return nil
+}
+
+// applyFilters runs the filters for the item's class, marking the item as
+// skipped or adding warnings.
+func (s *Service) applyFilters(item *PlanItem) error {
+	for _, f := range s.filters {
+		verdict, reason, err := f.Filter(*item.Schedule)
+		if err != nil {
+			return fmt.Errorf("unable to filter class %d: %w", item.Schedule.ID, err)
+		}
+		switch verdict {
+		case Flag:
+			fmt.Fprintf(s.log, "flagging class: %s\n", reason)
+			item.Warnings = append(item.Warnings, reason)
+		case Skip:
+			item.Action = ActionSkip
+			item.Reason = reason
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func formTriggerDescription(schedule cfa.Schedule) string {
+	return fmt.Sprintf("Scheduled trigger for class %s at %s", classTitle(schedule), schedule.Start)
 }
 
-func formScheduledEventDescription(schedule cfa.Schedule) string {
-	return fmt.Sprintf("Scheduled trigger for class %s at %s", strings.Replace(schedule.Title, "\n", " ", 1), schedule.Start)
+// formTriggerName derives a trigger's name from the calendar, the account and
+// the class, so re-planning the same class for the same account always lands
+// on the same trigger. The account is hashed to keep usernames out of
+// resource names and the name within EventBridge's 64 character limit.
+func formTriggerName(calendar, account string, classID int) string {
+	return fmt.Sprintf("%s%d", formTriggerPrefix(calendar, account), classID)
 }
-func formScheduledEventName(start time.Time) string {
+
+// formTriggerPrefix returns the name prefix shared by every trigger for the
+// calendar and account.
+func formTriggerPrefix(calendar, account string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(account)))
+
 	return fmt.Sprintf(
-		"Schedule.%s.%02d-%02dT%02d.%02d",
-		start.Weekday().String(),
-		start.Month(),
-		start.Day(),
-		start.Hour(),
-		start.Minute(),
+		triggerNamePrefix+"%s.%s.",
+		slug(calendar, 20),
+		hex.EncodeToString(sum[:4]),
 	)
 }
 
+// slug lowercases s and replaces anything but letters and digits with dashes,
+// truncating the result to max characters.
+func slug(s string, max int) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	out := strings.TrimSuffix(b.String(), "-")
+	if len(out) > max {
+		out = strings.TrimSuffix(out[:max], "-")
+	}
+
+	return out
+}
+
+// MatchesRequest reports whether the class is the one requested: its title
+// contains the requested class name and it starts at the requested minute.
+func MatchesRequest(sched cfa.Schedule, req cfa.ScheduleRequest) bool {
+	return strings.Contains(sched.Title, req.ClassName) && equalTimes(*sched.Start, *req.StartTime)
+}
+
 func equalTimes(t1, t2 time.Time) bool {
 	return t1.Year() == t2.Year() && t1.Month() == t2.Month() && t1.Day() == t2.Day() && t1.Hour() == t2.Hour() && t1.Minute() == t2.Minute()
 }
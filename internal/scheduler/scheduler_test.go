@@ -0,0 +1,534 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+const (
+	testAccount     = "me@example.com"
+	testCredentials = "rsvper/me"
+)
+
+type fakeChecker map[int]cfa.RSVPStatus
+
+func (c fakeChecker) CheckRSVP(sched cfa.Schedule) (cfa.RSVPStatus, error) {
+	return c[sched.ID], nil
+}
+
+type fakeBooker struct {
+	mu     sync.Mutex
+	booked []int
+	err    error
+}
+
+func (b *fakeBooker) Book(_ context.Context, task TaskRequest) (cfa.RSVPStatus, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.err != nil {
+		return 0, b.err
+	}
+	b.booked = append(b.booked, task.Schedule.ID)
+
+	return cfa.RSVPED, nil
+}
+
+// class returns a class and a request for it whose rsvp window opens in
+// windowIn.
+func class(id int, windowIn time.Duration) (cfa.Schedule, cfa.ScheduleRequest) {
+	start := time.Now().Add(cfa.MinimumRSVPTime + windowIn).Truncate(time.Minute)
+	sched := cfa.Schedule{ID: id, Title: "CrossFit Small Group Session", Start: &start}
+
+	return sched, cfa.ScheduleRequest{ClassName: "Small Group", StartTime: &start}
+}
+
+func newTestService(t *testing.T, backend *MemoryBackend, checker fakeChecker, account string) *Service {
+	t.Helper()
+	s, err := NewService(backend, checker)
+	if err != nil {
+		t.Fatalf("unable to create service: %v", err)
+	}
+	s.SetAccount(account)
+	s.SetCredentials(testCredentials)
+
+	return s
+}
+
+// seed plans and applies the requests so later runs see existing triggers.
+func seed(t *testing.T, s *Service, schedules []cfa.Schedule, requests []cfa.ScheduleRequest) {
+	t.Helper()
+	if _, err := s.ProcessRequests(context.Background(), schedules, requests); err != nil {
+		t.Fatalf("unable to seed triggers: %v", err)
+	}
+}
+
+func actions(plan *Plan) []string {
+	var out []string
+	for _, item := range plan.Items {
+		var id int
+		if item.Schedule != nil {
+			id = item.Schedule.ID
+		}
+		out = append(out, fmt.Sprintf("%d:%s", id, item.Action))
+	}
+
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func TestPlan(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	c2, r2 := class(2, 72*time.Hour)
+	missing := r1
+	missingStart := r1.StartTime.Add(time.Hour)
+	missing.StartTime = &missingStart
+
+	tests := []struct {
+		name     string
+		checker  fakeChecker
+		requests []cfa.ScheduleRequest
+		want     []string
+	}{
+		{
+			name:     "create",
+			requests: []cfa.ScheduleRequest{r1, r2},
+			want:     []string{"1:create", "2:create"},
+		},
+		{
+			name:     "skip already rsvped",
+			checker:  fakeChecker{1: cfa.RSVPED, 2: cfa.WAITLISTED},
+			requests: []cfa.ScheduleRequest{r1, r2},
+			want:     []string{"1:skip", "2:skip"},
+		},
+		{
+			name:     "skip duplicate and unmatched requests",
+			requests: []cfa.ScheduleRequest{r1, r1, missing},
+			want:     []string{"1:create", "1:skip", "0:skip"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, NewMemoryBackend(), tt.checker, testAccount)
+			plan, err := s.Plan([]cfa.Schedule{c1, c2}, tt.requests)
+			if err != nil {
+				t.Fatalf("unable to plan: %v", err)
+			}
+			if got := actions(plan); !equal(got, tt.want) {
+				t.Errorf("got actions %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanDoesNotWrite(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	backend := NewMemoryBackend()
+	s := newTestService(t, backend, nil, testAccount)
+	if _, err := s.Plan([]cfa.Schedule{c1}, []cfa.ScheduleRequest{r1}); err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+
+	triggers, err := backend.List()
+	if err != nil {
+		t.Fatalf("unable to list triggers: %v", err)
+	}
+	if len(triggers) > 0 {
+		t.Errorf("got %d triggers after planning, want none", len(triggers))
+	}
+}
+
+func TestApply(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	c2, r2 := class(2, 72*time.Hour)
+	backend := NewMemoryBackend()
+	s := newTestService(t, backend, nil, testAccount)
+
+	plan, err := s.ProcessRequests(context.Background(), []cfa.Schedule{c1, c2}, []cfa.ScheduleRequest{r1, r2})
+	if err != nil {
+		t.Fatalf("unable to process requests: %v", err)
+	}
+
+	triggers, err := backend.List()
+	if err != nil {
+		t.Fatalf("unable to list triggers: %v", err)
+	}
+	if len(triggers) != 2 {
+		t.Fatalf("got %d triggers, want 2", len(triggers))
+	}
+	for i, tr := range triggers {
+		if tr.Task.Schedule.ID != i+1 || tr.Task.Credentials != testCredentials {
+			t.Errorf("got trigger task %+v, want class %d with %s", tr.Task, i+1, testCredentials)
+		}
+		if got := plan.Items[i].TriggerID; got != tr.ID {
+			t.Errorf("got trigger id %q in the plan, want %q", got, tr.ID)
+		}
+	}
+}
+
+func TestPlanUpserts(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	c2, r2 := class(2, 72*time.Hour)
+	schedules := []cfa.Schedule{c1, c2}
+
+	tests := []struct {
+		name        string
+		credentials string
+		requests    []cfa.ScheduleRequest
+		want        []string
+	}{
+		{
+			name:     "unchanged",
+			requests: []cfa.ScheduleRequest{r1, r2},
+			want:     []string{"1:unchanged", "2:create"},
+		},
+		{
+			name:        "update on a changed task",
+			credentials: "rsvper/new",
+			requests:    []cfa.ScheduleRequest{r1},
+			want:        []string{"1:update"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewMemoryBackend()
+			seed(t, newTestService(t, backend, nil, testAccount), schedules, []cfa.ScheduleRequest{r1})
+			s := newTestService(t, backend, nil, testAccount)
+			if tt.credentials != "" {
+				s.SetCredentials(tt.credentials)
+			}
+
+			plan, err := s.Plan(schedules, tt.requests)
+			if err != nil {
+				t.Fatalf("unable to plan: %v", err)
+			}
+			if got := actions(plan); !equal(got, tt.want) {
+				t.Errorf("got actions %v, want %v", got, tt.want)
+			}
+			if got, want := plan.Items[0].TriggerName, formTriggerName(cfa.InHouseSessions, testAccount, 1); got != want {
+				t.Errorf("got trigger name %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanRerunIsIdempotent(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	c2, r2 := class(2, 72*time.Hour)
+	schedules := []cfa.Schedule{c1, c2}
+	requests := []cfa.ScheduleRequest{r1, r2}
+
+	s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+	seed(t, s, schedules, requests)
+	for run := 0; run < 2; run++ {
+		plan, err := s.ProcessRequests(context.Background(), schedules, requests)
+		if err != nil {
+			t.Fatalf("unable to process requests: %v", err)
+		}
+		if plan.HasChanges() {
+			t.Fatalf("run %d: got changes %v, want none", run, actions(plan))
+		}
+	}
+}
+
+func TestFormTriggerName(t *testing.T) {
+	name := formTriggerName(cfa.InHouseSessions, testAccount, 1)
+	if name != formTriggerName(cfa.InHouseSessions, strings.ToUpper(testAccount), 1) {
+		t.Error("got different names for the same account in another case")
+	}
+	for _, other := range []string{
+		formTriggerName(cfa.InHouseSessions, testAccount, 2),
+		formTriggerName(cfa.InHouseSessions, otherAccount, 1),
+		formTriggerName("Open Gym", testAccount, 1),
+	} {
+		if other == name {
+			t.Errorf("got %s for a different class, account or calendar", other)
+		}
+	}
+	// EventBridge schedule names are at most 64 characters
+	long := formTriggerName(strings.Repeat("calendar ", 10), testAccount, 1<<31-1)
+	if len(long) > 64 {
+		t.Errorf("got a %d character name %s", len(long), long)
+	}
+	if got, want := name, "Schedule.in-house-sessions."; !strings.HasPrefix(got, want) {
+		t.Errorf("got %s, want it to start with %s", got, want)
+	}
+}
+
+func TestPlanLeadTime(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	withLead := r1
+	withLead.LeadTime = 2 * time.Minute
+
+	tests := []struct {
+		name    string
+		lead    time.Duration
+		classes map[string]time.Duration
+		request cfa.ScheduleRequest
+		want    time.Duration
+	}{
+		{name: "default", request: r1, want: cfa.DefaultLeadTime},
+		{name: "configured", lead: 8 * time.Minute, request: r1, want: 8 * time.Minute},
+		{
+			name:    "longest matching class",
+			lead:    8 * time.Minute,
+			classes: map[string]time.Duration{"CrossFit": 3 * time.Minute, "Small Group": time.Minute},
+			request: r1,
+			want:    time.Minute,
+		},
+		{
+			name:    "request overrides class",
+			classes: map[string]time.Duration{"Small Group": time.Minute},
+			request: withLead,
+			want:    2 * time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+			if tt.lead > 0 {
+				s.SetLeadTime(tt.lead)
+			}
+			for class, d := range tt.classes {
+				s.SetClassLeadTime(class, d)
+			}
+
+			plan, err := s.Plan([]cfa.Schedule{c1}, []cfa.ScheduleRequest{tt.request})
+			if err != nil {
+				t.Fatalf("unable to plan: %v", err)
+			}
+			want := c1.Start.Add(-cfa.MinimumRSVPTime - tt.want)
+			if got := plan.Items[0].TriggerTime; got == nil || !got.Equal(want) {
+				t.Errorf("got trigger time %v, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanLeadTimeChangeUpdates(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	backend := NewMemoryBackend()
+	seed(t, newTestService(t, backend, nil, testAccount), []cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+
+	s := newTestService(t, backend, nil, testAccount)
+	s.SetLeadTime(8 * time.Minute)
+	plan, err := s.Plan([]cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	if got, want := actions(plan), []string{"1:update"}; !equal(got, want) {
+		t.Errorf("got actions %v, want %v", got, want)
+	}
+}
+
+func TestPlanOpenWindow(t *testing.T) {
+	open, openReq := class(3, -time.Minute)
+
+	t.Run("book with a booker", func(t *testing.T) {
+		s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+		s.SetBooker(&fakeBooker{})
+		plan, err := s.Plan([]cfa.Schedule{open}, []cfa.ScheduleRequest{openReq})
+		if err != nil {
+			t.Fatalf("unable to plan: %v", err)
+		}
+		if got, want := actions(plan), []string{"3:book"}; !equal(got, want) {
+			t.Errorf("got actions %v, want %v", got, want)
+		}
+	})
+
+	t.Run("trigger soon without a booker", func(t *testing.T) {
+		s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+		plan, err := s.Plan([]cfa.Schedule{open}, []cfa.ScheduleRequest{openReq})
+		if err != nil {
+			t.Fatalf("unable to plan: %v", err)
+		}
+		if got, want := actions(plan), []string{"3:create"}; !equal(got, want) {
+			t.Fatalf("got actions %v, want %v", got, want)
+		}
+		tt := plan.Items[0].TriggerTime
+		if tt == nil || !tt.After(time.Now()) || tt.Second() != 0 || tt.Nanosecond() != 0 {
+			t.Errorf("got trigger time %v, want a whole minute in the future", tt)
+		}
+	})
+
+	t.Run("stable trigger time on rerun", func(t *testing.T) {
+		s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+		seed(t, s, []cfa.Schedule{open}, []cfa.ScheduleRequest{openReq})
+		plan, err := s.Plan([]cfa.Schedule{open}, []cfa.ScheduleRequest{openReq})
+		if err != nil {
+			t.Fatalf("unable to plan: %v", err)
+		}
+		if got, want := actions(plan), []string{"3:unchanged"}; !equal(got, want) {
+			t.Errorf("got actions %v, want %v", got, want)
+		}
+	})
+}
+
+func TestApplyBooksOpenClasses(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	open, openReq := class(3, -time.Minute)
+	open2, openReq2 := class(4, -2*time.Minute)
+	backend := NewMemoryBackend()
+	booker := &fakeBooker{}
+	s := newTestService(t, backend, nil, testAccount)
+	s.SetBooker(booker)
+
+	schedules := []cfa.Schedule{c1, open, open2}
+	plan, err := s.ProcessRequests(context.Background(), schedules, []cfa.ScheduleRequest{r1, openReq, openReq2})
+	if err != nil {
+		t.Fatalf("unable to process requests: %v", err)
+	}
+
+	if _, err := backend.Get(formTriggerName(cfa.InHouseSessions, testAccount, 1)); err != nil {
+		t.Errorf("unable to get created trigger: %v", err)
+	}
+	if _, err := backend.Get(formTriggerName(cfa.InHouseSessions, testAccount, 3)); err != ErrTriggerNotFound {
+		t.Errorf("got %v for the booked class's trigger, want %v", err, ErrTriggerNotFound)
+	}
+	if len(booker.booked) != 2 {
+		t.Errorf("got booked classes %v, want 3 and 4", booker.booked)
+	}
+	for _, item := range plan.Items {
+		if item.Action == ActionBook && item.Status != cfa.RSVPED.String() {
+			t.Errorf("got status %q for class %d, want %q", item.Status, item.Schedule.ID, cfa.RSVPED.String())
+		}
+	}
+}
+
+func TestApplyBookingFailure(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	open, openReq := class(3, -time.Minute)
+	backend := NewMemoryBackend()
+	s := newTestService(t, backend, nil, testAccount)
+	s.SetBooker(&fakeBooker{err: fmt.Errorf("no spots")})
+
+	plan, err := s.ProcessRequests(context.Background(), []cfa.Schedule{open, c1}, []cfa.ScheduleRequest{openReq, r1})
+	if err == nil {
+		t.Fatal("got no error, want the booking failure")
+	}
+	if plan.Items[0].Reason == "" {
+		t.Error("got no reason on the failed booking")
+	}
+	// the failed booking doesn't stop the rest of the plan
+	if _, err := backend.Get(formTriggerName(cfa.InHouseSessions, testAccount, 1)); err != nil {
+		t.Errorf("unable to get created trigger: %v", err)
+	}
+}
+
+func TestPlanPrune(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	c2, r2 := class(2, 72*time.Hour)
+	schedules := []cfa.Schedule{c1, c2}
+
+	tests := []struct {
+		name     string
+		checker  fakeChecker
+		requests []cfa.ScheduleRequest
+		want     []string
+	}{
+		{
+			name:     "keeps requested triggers",
+			requests: []cfa.ScheduleRequest{r1, r2},
+			want:     []string{"1:unchanged", "2:unchanged"},
+		},
+		{
+			name:     "deletes unrequested triggers",
+			requests: []cfa.ScheduleRequest{r1},
+			want:     []string{"1:unchanged", "2:delete"},
+		},
+		{
+			name:     "deletes skipped classes' triggers",
+			checker:  fakeChecker{2: cfa.RSVPED},
+			requests: []cfa.ScheduleRequest{r1, r2},
+			want:     []string{"1:unchanged", "2:skip", "2:delete"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewMemoryBackend()
+			seed(t, newTestService(t, backend, nil, testAccount), schedules, []cfa.ScheduleRequest{r1, r2})
+			// another account's triggers are never pruned
+			seed(t, newTestService(t, backend, nil, otherAccount), schedules, []cfa.ScheduleRequest{r1, r2})
+
+			s := newTestService(t, backend, tt.checker, testAccount)
+			plan, err := s.Plan(schedules, tt.requests)
+			if err != nil {
+				t.Fatalf("unable to plan: %v", err)
+			}
+			if err := s.PlanPrune(plan, time.Now()); err != nil {
+				t.Fatalf("unable to prune: %v", err)
+			}
+			if got := actions(plan); !equal(got, tt.want) {
+				t.Fatalf("got actions %v, want %v", got, tt.want)
+			}
+			if err := s.Apply(context.Background(), plan); err != nil {
+				t.Fatalf("unable to apply: %v", err)
+			}
+
+			triggers, err := backend.List()
+			if err != nil {
+				t.Fatalf("unable to list triggers: %v", err)
+			}
+			if got, want := len(triggers), 4-plan.Count(ActionDelete); got != want {
+				t.Errorf("got %d triggers, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestPlanPruneWithoutStart(t *testing.T) {
+	backend := NewMemoryBackend()
+	backend.Create(Trigger{
+		Name: formTriggerName(cfa.InHouseSessions, testAccount, 9),
+		Time: time.Now().Add(time.Hour),
+		Task: TaskRequest{Schedule: cfa.Schedule{ID: 9, Title: "Open Gym"}, Account: testAccount},
+	})
+
+	s := newTestService(t, backend, nil, testAccount)
+	plan, err := s.Plan(nil, nil)
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	if err := s.PlanPrune(plan, time.Now()); err != nil {
+		t.Fatalf("unable to prune: %v", err)
+	}
+	if got, want := actions(plan), []string{"9:delete"}; !equal(got, want) {
+		t.Fatalf("got actions %v, want %v", got, want)
+	}
+	var out strings.Builder
+	plan.WriteSummary(&out)
+	if !strings.Contains(out.String(), "Open Gym (id: 9) @ unknown time, delete") {
+		t.Errorf("got summary %q", out.String())
+	}
+}
+
+func TestSetLog(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	s := newTestService(t, NewMemoryBackend(), nil, testAccount)
+	var log strings.Builder
+	s.SetLog(&log)
+
+	if _, err := s.Plan([]cfa.Schedule{c1}, []cfa.ScheduleRequest{r1}); err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	if !strings.Contains(log.String(), "planned trigger") {
+		t.Errorf("got log %q, want the planning progress", log.String())
+	}
+}
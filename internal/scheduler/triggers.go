@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const triggerNamePrefix = "Schedule."
+
+// ListTriggers returns the triggers created by the scheduler along with the
+// task request each one carries.
+func (s *Service) ListTriggers() ([]Trigger, error) {
+	triggers, err := s.backend.List()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list triggers: %w", err)
+	}
+
+	return triggers, nil
+}
+
+// DeleteTrigger deletes the trigger with the given name.
+func (s *Service) DeleteTrigger(name string) error {
+	if err := s.backend.Delete(name); err != nil {
+		return fmt.Errorf("unable to delete trigger %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// CancelTriggers deletes every trigger of the service's account and calendar
+// that match returns true for and returns the deleted triggers. Other
+// accounts' triggers are never touched.
+func (s *Service) CancelTriggers(match func(Trigger) bool) ([]Trigger, error) {
+	triggers, err := s.ListTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	var cancelled []Trigger
+	for i := range triggers {
+		if !s.owns(triggers[i]) || !match(triggers[i]) {
+			continue
+		}
+		if err := s.DeleteTrigger(triggers[i].Name); err != nil {
+			return cancelled, err
+		}
+		cancelled = append(cancelled, triggers[i])
+	}
+
+	return cancelled, nil
+}
+
+// diff reports what upsert would do with the trigger without doing it, along
+// with the existing trigger's id.
+func (s *Service) diff(t Trigger) (Action, string, error) {
+	existing, err := s.backend.Get(t.Name)
+	if errors.Is(err, ErrTriggerNotFound) {
+		return ActionCreate, "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get trigger %s: %w", t.Name, err)
+	}
+	if sameTrigger(*existing, t) {
+		return ActionUnchanged, existing.ID, nil
+	}
+
+	return ActionUpdate, existing.ID, nil
+}
+
+// upsert creates the trigger, or updates it in place if a trigger with the
+// same name already exists. Triggers that already match are left alone.
+func (s *Service) upsert(t Trigger) (Action, string, error) {
+	existing, err := s.backend.Get(t.Name)
+	if errors.Is(err, ErrTriggerNotFound) {
+		id, err := s.backend.Create(t)
+		if err != nil {
+			return "", "", fmt.Errorf("unable to create trigger %s: %w", t.Name, err)
+		}
+		return ActionCreate, id, nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to get trigger %s: %w", t.Name, err)
+	}
+
+	if sameTrigger(*existing, t) {
+		return ActionUnchanged, existing.ID, nil
+	}
+	id, err := s.backend.Update(t)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to update trigger %s: %w", t.Name, err)
+	}
+
+	return ActionUpdate, id, nil
+}
+
+// sameTrigger compares the parts of two triggers the scheduler sets.
+func sameTrigger(a, b Trigger) bool {
+	if !a.Time.Equal(b.Time) || a.Description != b.Description {
+		return false
+	}
+	taskA, errA := json.Marshal(a.Task)
+	taskB, errB := json.Marshal(b.Task)
+
+	return errA == nil && errB == nil && bytes.Equal(taskA, taskB)
+}
+
+// GetTrigger returns the trigger with the given name.
+func (s *Service) GetTrigger(name string) (*Trigger, error) {
+	t, err := s.backend.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get trigger %s: %w", name, err)
+	}
+
+	return t, nil
+}
+
+// PendingTriggers returns the triggers that haven't fired yet, ordered by
+// trigger time.
+func (s *Service) PendingTriggers(now time.Time) ([]Trigger, error) {
+	triggers, err := s.ListTriggers()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Trigger
+	for i := range triggers {
+		if triggers[i].Pending(now) {
+			pending = append(pending, triggers[i])
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Time.Before(pending[j].Time)
+	})
+
+	return pending, nil
+}
+
+// PurgeTriggers deletes the account's triggers whose trigger time has
+// passed, whether they fired or not, and returns them.
+func (s *Service) PurgeTriggers(now time.Time) ([]Trigger, error) {
+	return s.CancelTriggers(func(t Trigger) bool {
+		return !t.Time.After(now)
+	})
+}
+
+// CollectGarbage deletes the account's triggers whose class has already
+// started, whether they fired or not, and returns them.
+func (s *Service) CollectGarbage(now time.Time) ([]Trigger, error) {
+	return s.CancelTriggers(func(t Trigger) bool {
+		return t.Task.Schedule.Start != nil && !t.Task.Schedule.Start.After(now)
+	})
+}
+
+// owns reports whether the trigger was created for the service's account and
+// calendar.
+func (s *Service) owns(t Trigger) bool {
+	return strings.HasPrefix(t.Name, formTriggerPrefix(s.calendar, s.account))
+}
+
+// Pending reports whether the trigger is still waiting to fire.
+func (t Trigger) Pending(now time.Time) bool {
+	return t.Time.After(now) && t.State != disabledState
+}
+
+// MatchClass matches triggers whose class title contains text, ignoring case.
+func MatchClass(text string) func(Trigger) bool {
+	text = strings.ToLower(text)
+	return func(t Trigger) bool {
+		return strings.Contains(strings.ToLower(t.Task.Schedule.Title), text)
+	}
+}
+
+// MatchDate matches triggers whose class starts on the given day in loc.
+func MatchDate(day time.Time, loc *time.Location) func(Trigger) bool {
+	y, m, d := day.In(loc).Date()
+	return func(t Trigger) bool {
+		if t.Task.Schedule.Start == nil {
+			return false
+		}
+		ty, tm, td := t.Task.Schedule.Start.In(loc).Date()
+		return ty == y && tm == m && td == d
+	}
+}
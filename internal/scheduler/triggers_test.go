@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+const otherAccount = "other@example.com"
+
+func TestCancelTriggersIsScoped(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	backend := NewMemoryBackend()
+	seed(t, newTestService(t, backend, nil, testAccount), []cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+	seed(t, newTestService(t, backend, nil, otherAccount), []cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+
+	s := newTestService(t, backend, nil, testAccount)
+	cancelled, err := s.CancelTriggers(func(Trigger) bool { return true })
+	if err != nil {
+		t.Fatalf("unable to cancel triggers: %v", err)
+	}
+	if len(cancelled) != 1 || cancelled[0].Task.Account != testAccount {
+		t.Errorf("got cancelled %v, want only %s's trigger", cancelled, testAccount)
+	}
+	if _, err := backend.Get(formTriggerName(cfa.InHouseSessions, otherAccount, 1)); err != nil {
+		t.Errorf("unable to get the other account's trigger: %v", err)
+	}
+}
+
+func TestPurgeTriggersIsScoped(t *testing.T) {
+	c1, r1 := class(1, 48*time.Hour)
+	backend := NewMemoryBackend()
+	seed(t, newTestService(t, backend, nil, testAccount), []cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+	seed(t, newTestService(t, backend, nil, otherAccount), []cfa.Schedule{c1}, []cfa.ScheduleRequest{r1})
+
+	// purge as if every trigger's time had passed
+	s := newTestService(t, backend, nil, testAccount)
+	purged, err := s.PurgeTriggers(c1.Start.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unable to purge triggers: %v", err)
+	}
+	if len(purged) != 1 || purged[0].Task.Account != testAccount {
+		t.Errorf("got purged %v, want only %s's trigger", purged, testAccount)
+	}
+	if _, err := backend.Get(formTriggerName(cfa.InHouseSessions, otherAccount, 1)); err != nil {
+		t.Errorf("unable to get the other account's trigger: %v", err)
+	}
+}
+
+func TestPlanDeletesStartedClasses(t *testing.T) {
+	backend := NewMemoryBackend()
+	started := time.Now().Add(-time.Hour)
+	for _, account := range []string{testAccount, otherAccount} {
+		backend.Create(Trigger{
+			Name: formTriggerName(cfa.InHouseSessions, account, 9),
+			Time: started.Add(-cfa.MinimumRSVPTime),
+			Task: TaskRequest{Schedule: cfa.Schedule{ID: 9, Start: &started}, Account: account},
+		})
+	}
+
+	s := newTestService(t, backend, nil, testAccount)
+	plan, err := s.Plan(nil, nil)
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	if got, want := actions(plan), []string{"9:delete"}; !equal(got, want) {
+		t.Fatalf("got actions %v, want %v", got, want)
+	}
+	if got, want := plan.Items[0].TriggerName, formTriggerName(cfa.InHouseSessions, testAccount, 9); got != want {
+		t.Errorf("got deletion of %s, want %s", got, want)
+	}
+}
+
+func TestCollectGarbage(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+	started := now.Add(-time.Hour)
+	upcoming := now.Add(time.Hour)
+	for id, start := range map[int]*time.Time{1: &started, 2: &upcoming, 3: nil} {
+		backend.Create(Trigger{
+			Name: formTriggerName(cfa.InHouseSessions, testAccount, id),
+			Time: now.Add(-time.Minute),
+			Task: TaskRequest{Schedule: cfa.Schedule{ID: id, Start: start}, Account: testAccount},
+		})
+	}
+	backend.Create(Trigger{
+		Name: formTriggerName(cfa.InHouseSessions, otherAccount, 1),
+		Task: TaskRequest{Schedule: cfa.Schedule{ID: 1, Start: &started}, Account: otherAccount},
+	})
+
+	s := newTestService(t, backend, nil, testAccount)
+	deleted, err := s.CollectGarbage(now)
+	if err != nil {
+		t.Fatalf("unable to collect garbage: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].Task.Schedule.ID != 1 || deleted[0].Task.Account != testAccount {
+		t.Errorf("got deleted %v, want only class 1's trigger for %s", deleted, testAccount)
+	}
+	triggers, err := backend.List()
+	if err != nil {
+		t.Fatalf("unable to list triggers: %v", err)
+	}
+	if len(triggers) != 3 {
+		t.Errorf("got %d triggers left, want 3", len(triggers))
+	}
+}
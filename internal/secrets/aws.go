@@ -0,0 +1,51 @@
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// SecretsManagerStore resolves references as AWS Secrets Manager secret names
+// or ARNs. Each secret is a json object with username and password fields.
+type SecretsManagerStore struct {
+	client *secretsmanager.SecretsManager
+}
+
+func NewSecretsManagerStore(sess *session.Session) (*SecretsManagerStore, error) {
+	if sess == nil {
+		return nil, fmt.Errorf("aws session cannot be nil")
+	}
+
+	return &SecretsManagerStore{
+		client: secretsmanager.New(sess),
+	}, nil
+}
+
+func (s *SecretsManagerStore) Credentials(ref string) (Credentials, error) {
+	out, err := s.client.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(ref),
+	})
+	if err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return Credentials{}, fmt.Errorf("%w: %s", ErrNotFound, ref)
+		}
+		return Credentials{}, fmt.Errorf("unable to get secret %s: %w", ref, err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal([]byte(aws.StringValue(out.SecretString)), &creds); err != nil {
+		return Credentials{}, fmt.Errorf("unable to decode secret %s: %w", ref, err)
+	}
+	if err := creds.validate(); err != nil {
+		return Credentials{}, fmt.Errorf("invalid secret %s: %w", ref, err)
+	}
+
+	return creds, nil
+}
@@ -0,0 +1,46 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FileStore resolves references from a local json file mapping each
+// reference to its credentials:
+//
+//	{"me": {"username": "me@example.com", "password": "..."}}
+//
+// The file is read on every lookup so edits are picked up without a restart.
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("secrets file path cannot be empty")
+	}
+
+	return &FileStore{path: path}, nil
+}
+
+func (s *FileStore) Credentials(ref string) (Credentials, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("unable to read secrets file: %w", err)
+	}
+	var all map[string]Credentials
+	if err := json.Unmarshal(data, &all); err != nil {
+		return Credentials{}, fmt.Errorf("unable to decode secrets file: %w", err)
+	}
+
+	creds, ok := all[ref]
+	if !ok {
+		return Credentials{}, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	if err := creds.validate(); err != nil {
+		return Credentials{}, fmt.Errorf("invalid credentials %s: %w", ref, err)
+	}
+
+	return creds, nil
+}
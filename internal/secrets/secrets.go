@@ -0,0 +1,54 @@
+// Package secrets resolves the credential references carried by task
+// requests into the account's username and password, so triggers never hold
+// a live session.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/itsHabib/rsvper/internal/config"
+)
+
+// ErrNotFound is returned when a reference doesn't resolve to any credentials.
+var ErrNotFound = errors.New("credentials not found")
+
+// Credentials are the username and password for a gym account.
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func (c Credentials) validate() error {
+	if c.Username == "" || c.Password == "" {
+		return fmt.Errorf("unable to read username or password")
+	}
+
+	return nil
+}
+
+// Store resolves credential references.
+type Store interface {
+	Credentials(ref string) (Credentials, error)
+}
+
+// Open returns the secret store selected by the config.
+func Open(cfg *config.Config) (Store, error) {
+	switch cfg.SecretStore {
+	case config.SecretStoreAWS:
+		sess, err := session.NewSession(&aws.Config{
+			Region: aws.String(cfg.AWSRegion),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to create new session: %w", err)
+		}
+		return NewSecretsManagerStore(sess)
+	case config.SecretStoreFile:
+		return NewFileStore(cfg.SecretsFile)
+	default:
+		return nil, fmt.Errorf("unknown secret store %q", cfg.SecretStore)
+	}
+}
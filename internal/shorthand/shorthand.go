@@ -0,0 +1,231 @@
+// Package shorthand parses human friendly class requests such as
+// "tue 6:30am small group", "next fri 12:00 crossfit" or
+// "2026-10-20 17:30 Range & Resilience".
+//
+// A shorthand request is made of a day, a time and a class name, in that
+// order. The day is one of:
+//   - a date in yyyy-mm-dd format
+//   - "today" or "tomorrow"
+//   - a weekday such as "tue" or "tuesday", the next time that weekday comes
+//     around, today included when the time hasn't passed yet
+//   - "next" followed by a weekday, that weekday in the following calendar
+//     week, weeks starting on monday
+//
+// The time is either 24 hour ("17:30") or 12 hour with an am/pm suffix
+// ("6:30am", "6pm", "6:30 pm"). The remaining words are matched case
+// insensitively against the class titles in the schedule.
+package shorthand
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+const dateLayout = "2006-01-02"
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+// Query is a parsed shorthand request that still needs to be resolved
+// against the schedule to find the class.
+type Query struct {
+	Text  string
+	Start time.Time
+	Class string
+	// LeadTime is carried over to the resolved request.
+	LeadTime time.Duration
+}
+
+// Parse parses a shorthand request. Relative days are resolved from now in
+// the given location, which should be the gym's timezone.
+func Parse(text string, now time.Time, loc *time.Location) (Query, error) {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return Query{}, fmt.Errorf("empty request")
+	}
+	now = now.In(loc)
+
+	day, rest, err := parseDay(words, now, loc)
+	if err != nil {
+		return Query{}, err
+	}
+	hour, minute, rest, err := parseClock(rest)
+	if err != nil {
+		return Query{}, err
+	}
+	if len(rest) == 0 {
+		return Query{}, fmt.Errorf("missing class name in %q", text)
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+	// a bare weekday that already passed today means next week
+	if start.Before(now) && isWeekday(strings.ToLower(words[0])) {
+		start = start.AddDate(0, 0, 7)
+	}
+
+	return Query{
+		Text:  text,
+		Start: start,
+		Class: strings.Join(rest, " "),
+	}, nil
+}
+
+func parseDay(words []string, now time.Time, loc *time.Location) (time.Time, []string, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	first := strings.ToLower(words[0])
+	switch {
+	case first == "today":
+		return today, words[1:], nil
+	case first == "tomorrow":
+		return today.AddDate(0, 0, 1), words[1:], nil
+	case first == "next":
+		if len(words) < 2 || !isWeekday(strings.ToLower(words[1])) {
+			return time.Time{}, nil, fmt.Errorf("expected a weekday after %q", words[0])
+		}
+		wd := weekdays[strings.ToLower(words[1])]
+		// start of next week, weeks start on monday
+		daysToMonday := (7 - int(today.Weekday()) + int(time.Monday)) % 7
+		if daysToMonday == 0 {
+			daysToMonday = 7
+		}
+		monday := today.AddDate(0, 0, daysToMonday)
+		offset := (int(wd) - int(time.Monday) + 7) % 7
+		return monday.AddDate(0, 0, offset), words[2:], nil
+	case isWeekday(first):
+		offset := (int(weekdays[first]) - int(today.Weekday()) + 7) % 7
+		return today.AddDate(0, 0, offset), words[1:], nil
+	default:
+		day, err := time.ParseInLocation(dateLayout, words[0], loc)
+		if err != nil {
+			return time.Time{}, nil, fmt.Errorf("unrecognized day %q, expected a weekday, today, tomorrow, next <weekday> or yyyy-mm-dd", words[0])
+		}
+		return day, words[1:], nil
+	}
+}
+
+func parseClock(words []string) (int, int, []string, error) {
+	if len(words) == 0 {
+		return 0, 0, nil, fmt.Errorf("missing time")
+	}
+	text := strings.ToLower(words[0])
+	rest := words[1:]
+	// allow the meridiem to be its own word, e.g. "6:30 pm"
+	if len(rest) > 0 {
+		if next := strings.ToLower(rest[0]); next == "am" || next == "pm" {
+			text += next
+			rest = rest[1:]
+		}
+	}
+
+	var meridiem string
+	if strings.HasSuffix(text, "am") || strings.HasSuffix(text, "pm") {
+		meridiem = text[len(text)-2:]
+		text = text[:len(text)-2]
+	}
+
+	hourText, minuteText := text, "0"
+	if i := strings.Index(text, ":"); i >= 0 {
+		hourText, minuteText = text[:i], text[i+1:]
+	} else if meridiem == "" {
+		return 0, 0, nil, fmt.Errorf("unrecognized time %q, expected 17:30 or 6:30am", words[0])
+	}
+	hour, err := strconv.Atoi(hourText)
+	if err != nil {
+		return 0, 0, nil, fmt.Errorf("unrecognized time %q, expected 17:30 or 6:30am", words[0])
+	}
+	minute, err := strconv.Atoi(minuteText)
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, nil, fmt.Errorf("invalid minutes in time %q", words[0])
+	}
+
+	if meridiem == "" {
+		if hour < 0 || hour > 23 {
+			return 0, 0, nil, fmt.Errorf("invalid hour in time %q", words[0])
+		}
+		return hour, minute, rest, nil
+	}
+	if hour < 1 || hour > 12 {
+		return 0, 0, nil, fmt.Errorf("invalid hour in time %q", words[0])
+	}
+	hour %= 12
+	if meridiem == "pm" {
+		hour += 12
+	}
+
+	return hour, minute, rest, nil
+}
+
+func isWeekday(word string) bool {
+	_, ok := weekdays[word]
+	return ok
+}
+
+// Matches reports whether every word of the class query is found in the class
+// title, ignoring case.
+func Matches(class, title string) bool {
+	title = strings.ToLower(title)
+	for _, word := range strings.Fields(strings.ToLower(class)) {
+		if !strings.Contains(title, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Resolve finds the class the query refers to in the schedule and returns a
+// concrete request for it. It fails if no class, or more than one distinct
+// class, matches.
+func Resolve(q Query, schedules []cfa.Schedule) (cfa.ScheduleRequest, error) {
+	var names []string
+	seen := make(map[string]bool)
+	for i := range schedules {
+		s := schedules[i].Start
+		if s == nil || !sameMinute(*s, q.Start) {
+			continue
+		}
+		name := className(schedules[i].Title)
+		if !Matches(q.Class, name) || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	switch len(names) {
+	case 0:
+		return cfa.ScheduleRequest{}, fmt.Errorf("no class matching %q at %s", q.Class, q.Start.Format("Mon Jan 2 15:04"))
+	case 1:
+		start := q.Start
+		return cfa.ScheduleRequest{ClassName: names[0], StartTime: &start, LeadTime: q.LeadTime}, nil
+	default:
+		return cfa.ScheduleRequest{}, fmt.Errorf("%q at %s is ambiguous, matches: %s", q.Class, q.Start.Format("Mon Jan 2 15:04"), strings.Join(names, ", "))
+	}
+}
+
+// className returns the class name from a schedule title, which has the
+// class name on the first line and the coach on the second.
+func className(title string) string {
+	if i := strings.Index(title, "\n"); i >= 0 {
+		return strings.TrimSpace(title[:i])
+	}
+
+	return strings.TrimSpace(title)
+}
+
+// sameMinute compares wall clock times, the same way the scheduler matches
+// requests to classes.
+func sameMinute(t1, t2 time.Time) bool {
+	return t1.Year() == t2.Year() && t1.Month() == t2.Month() && t1.Day() == t2.Day() && t1.Hour() == t2.Hour() && t1.Minute() == t2.Minute()
+}
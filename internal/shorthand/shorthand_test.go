@@ -0,0 +1,160 @@
+package shorthand
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/itsHabib/rsvper/internal/cfa"
+)
+
+func testLocation(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(cfa.Timezone)
+	if err != nil {
+		t.Fatalf("unable to load timezone: %v", err)
+	}
+
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	loc := testLocation(t)
+	// a wednesday morning
+	wed := time.Date(2026, 10, 21, 10, 0, 0, 0, loc)
+	sun := time.Date(2026, 10, 25, 10, 0, 0, 0, loc)
+	mon := time.Date(2026, 10, 26, 10, 0, 0, 0, loc)
+	// clocks fall back at 2am on sunday, november 1st
+	beforeDST := time.Date(2026, 10, 31, 10, 0, 0, 0, loc)
+
+	tests := []struct {
+		name  string
+		text  string
+		now   time.Time
+		start string
+		class string
+	}{
+		{name: "date", text: "2026-10-20 17:30 Range & Resilience", now: wed, start: "2026-10-20T17:30:00-05:00", class: "Range & Resilience"},
+		{name: "today", text: "today 5:30pm small group", now: wed, start: "2026-10-21T17:30:00-05:00", class: "small group"},
+		{name: "tomorrow", text: "tomorrow 6am small group", now: wed, start: "2026-10-22T06:00:00-05:00", class: "small group"},
+		{name: "weekday later this week", text: "fri 12:00 crossfit", now: wed, start: "2026-10-23T12:00:00-05:00", class: "crossfit"},
+		{name: "full weekday name", text: "Friday 12:00 crossfit", now: wed, start: "2026-10-23T12:00:00-05:00", class: "crossfit"},
+		{name: "weekday earlier in the week", text: "tue 6:30am small group", now: wed, start: "2026-10-27T06:30:00-05:00", class: "small group"},
+		{name: "today's weekday later today", text: "wed 17:30 small group", now: wed, start: "2026-10-21T17:30:00-05:00", class: "small group"},
+		{name: "today's weekday already passed", text: "wed 9:00 small group", now: wed, start: "2026-10-28T09:00:00-05:00", class: "small group"},
+		{name: "next weekday later this week", text: "next fri 12:00 crossfit", now: wed, start: "2026-10-30T12:00:00-05:00", class: "crossfit"},
+		{name: "next weekday earlier in the week", text: "next mon 6am small group", now: wed, start: "2026-10-26T06:00:00-05:00", class: "small group"},
+		{name: "next today's weekday", text: "next wed 6am small group", now: wed, start: "2026-10-28T06:00:00-05:00", class: "small group"},
+		{name: "next sunday ends next week", text: "next sun 9am small group", now: wed, start: "2026-11-01T09:00:00-06:00", class: "small group"},
+		{name: "next monday on a sunday", text: "next mon 6am small group", now: sun, start: "2026-10-26T06:00:00-05:00", class: "small group"},
+		{name: "next sunday on a sunday", text: "next sun 9am small group", now: sun, start: "2026-11-01T09:00:00-06:00", class: "small group"},
+		{name: "next monday on a monday", text: "next mon 6am small group", now: mon, start: "2026-11-02T06:00:00-06:00", class: "small group"},
+		{name: "sunday after passing on a sunday", text: "sun 8am small group", now: sun, start: "2026-11-01T08:00:00-06:00", class: "small group"},
+		{name: "weekday across dst", text: "tue 6:30am small group", now: beforeDST, start: "2026-11-03T06:30:00-06:00", class: "small group"},
+		{name: "tomorrow across dst", text: "tomorrow 9am small group", now: beforeDST, start: "2026-11-01T09:00:00-06:00", class: "small group"},
+		{name: "noon", text: "fri 12pm crossfit", now: wed, start: "2026-10-23T12:00:00-05:00", class: "crossfit"},
+		{name: "midnight", text: "fri 12:15am crossfit", now: wed, start: "2026-10-23T00:15:00-05:00", class: "crossfit"},
+		{name: "pm hour only", text: "fri 6pm crossfit", now: wed, start: "2026-10-23T18:00:00-05:00", class: "crossfit"},
+		{name: "separate meridiem", text: "fri 6:30 PM crossfit", now: wed, start: "2026-10-23T18:30:00-05:00", class: "crossfit"},
+		{name: "24 hour", text: "fri 06:30 crossfit", now: wed, start: "2026-10-23T06:30:00-05:00", class: "crossfit"},
+		{name: "now in another zone", text: "today 6am small group", now: wed.Add(-6 * time.Hour).UTC(), start: "2026-10-21T06:00:00-05:00", class: "small group"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := Parse(tt.text, tt.now, loc)
+			if err != nil {
+				t.Fatalf("unable to parse: %v", err)
+			}
+			if got := q.Start.Format(time.RFC3339); got != tt.start {
+				t.Errorf("got start %s, want %s", got, tt.start)
+			}
+			if q.Class != tt.class {
+				t.Errorf("got class %q, want %q", q.Class, tt.class)
+			}
+			if q.Text != tt.text {
+				t.Errorf("got text %q, want %q", q.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	loc := testLocation(t)
+	now := time.Date(2026, 10, 21, 10, 0, 0, 0, loc)
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "  ", want: "empty request"},
+		{text: "someday 6am small group", want: `unrecognized day "someday", expected a weekday, today, tomorrow, next <weekday> or yyyy-mm-dd`},
+		{text: "2026-13-01 6am small group", want: `unrecognized day "2026-13-01", expected a weekday, today, tomorrow, next <weekday> or yyyy-mm-dd`},
+		{text: "next week 6am small group", want: `expected a weekday after "next"`},
+		{text: "next", want: `expected a weekday after "next"`},
+		{text: "tue", want: "missing time"},
+		{text: "tue 6 small group", want: `unrecognized time "6", expected 17:30 or 6:30am`},
+		{text: "tue six:30 small group", want: `unrecognized time "six:30", expected 17:30 or 6:30am`},
+		{text: "tue 24:00 small group", want: `invalid hour in time "24:00"`},
+		{text: "tue 13pm small group", want: `invalid hour in time "13pm"`},
+		{text: "tue 0:30am small group", want: `invalid hour in time "0:30am"`},
+		{text: "tue 6:60 small group", want: `invalid minutes in time "6:60"`},
+		{text: "tue 6:30am", want: `missing class name in "tue 6:30am"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := Parse(tt.text, now, loc)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	loc := testLocation(t)
+	start := time.Date(2026, 10, 20, 17, 30, 0, 0, loc)
+	later := start.Add(time.Hour)
+	schedules := []cfa.Schedule{
+		{ID: 1, Title: "CrossFit Small Group Session\nSam", Start: &start},
+		// the same class with another coach isn't ambiguous
+		{ID: 2, Title: "CrossFit Small Group Session\nAlex", Start: &start},
+		{ID: 3, Title: "Range & Resilience\nSam", Start: &start},
+		{ID: 4, Title: "Range & Resilience\nSam", Start: &later},
+		{ID: 5, Title: "Open Gym"},
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		class string
+		err   string
+	}{
+		{name: "match", query: Query{Class: "small group", Start: start, LeadTime: 3 * time.Minute}, class: "CrossFit Small Group Session"},
+		{name: "match ignores case", query: Query{Class: "RANGE", Start: later}, class: "Range & Resilience"},
+		// the same wall clock time in another zone doesn't match
+		{name: "match compares wall clock", query: Query{Class: "range", Start: later.UTC()}, err: `no class matching "range" at Tue Oct 20 23:30`},
+		{name: "every word must match", query: Query{Class: "small range", Start: start}, err: `no class matching "small range" at Tue Oct 20 17:30`},
+		{name: "no class at that time", query: Query{Class: "small group", Start: later}, err: `no class matching "small group" at Tue Oct 20 18:30`},
+		{name: "ambiguous", query: Query{Class: "s", Start: start}, err: `"s" at Tue Oct 20 17:30 is ambiguous, matches: CrossFit Small Group Session, Range & Resilience`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := Resolve(tt.query, schedules)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("got %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unable to resolve: %v", err)
+			}
+			if req.ClassName != tt.class || !req.StartTime.Equal(tt.query.Start) || req.LeadTime != tt.query.LeadTime {
+				t.Errorf("got %+v, want %s at %s", req, tt.class, tt.query.Start)
+			}
+		})
+	}
+}
@@ -1,6 +1,18 @@
 set -ex
 
+# FUNCTION_NAME and AWS_REGION select the lambda to update
+FUNCTION_NAME="${FUNCTION_NAME:-RSVPer}"
+AWS_REGION="${AWS_REGION:-us-east-2}"
+
 cd cmd/rsvp-lambda
 GOOS=linux GOARCH=amd64 go build -o main main.go
 zip main.zip main
-aws lambda update-function-code --function-name RSVPer --region us-east-2 --zip-file fileb://main.zip
+aws lambda update-function-code --function-name "$FUNCTION_NAME" --region "$AWS_REGION" --zip-file fileb://main.zip
+
+# DEAD_LETTER_ARN, when set, receives bookings that still fail after lambda's
+# async retries, so `scheduler replay -dlq` sees them. Use the same queue as
+# deadLetterArn.
+if [ -n "$DEAD_LETTER_ARN" ]; then
+  aws lambda put-function-event-invoke-config --function-name "$FUNCTION_NAME" --region "$AWS_REGION" \
+    --destination-config "{\"OnFailure\":{\"Destination\":\"$DEAD_LETTER_ARN\"}}"
+fi